@@ -0,0 +1,212 @@
+package clone
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/jxsl13/module-migration/config"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/spf13/cobra"
+)
+
+func NewCloneCmd() *cobra.Command {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+
+	cloneContext := cloneContext{
+		Ctx: ctx,
+	}
+
+	// cmd represents the run command
+	cmd := &cobra.Command{
+		Use:   "clone",
+		Short: "clones every old repository of the mapping file into <root>/<host>/<project>/<repo> and fetches repositories that were already cloned",
+		Args:  cobra.ExactArgs(1),
+		RunE:  cloneContext.RunE,
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+
+			cancel()
+			return nil
+		},
+	}
+
+	// register flags but defer parsing and validation of the final values
+	cmd.PreRunE = cloneContext.PreRunE(cmd)
+
+	return cmd
+}
+
+type cloneContext struct {
+	Ctx      context.Context
+	Config   *CloneConfig
+	RootPath string `koanf:"root.path" short:"" description:"root directory of the clones"`
+}
+
+func (c *cloneContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
+	c.Config = &CloneConfig{
+		RemoteName: "origin",
+		Jobs:       "0",
+		KeepGoing:  true,
+		CSVPath:    "./mapping.csv",
+		Comma:      ";", // default separator
+		OldColumn:  "0",
+		NewColumn:  "1",
+	}
+
+	runParser := config.RegisterFlags(c.Config, true, cmd)
+
+	return func(cmd *cobra.Command, args []string) error {
+		abs, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		c.RootPath = abs
+
+		return runParser()
+	}
+}
+
+func (c *cloneContext) RunE(cmd *cobra.Command, args []string) (err error) {
+	started := time.Now()
+
+	// only the old urls are cloned, chains do not need to be resolved
+	m, err := mapping.Load(
+		c.Config.CSVPath,
+		c.Config.OldColumnIndex(),
+		c.Config.NewColumnIndex(),
+		c.Config.CommaRune(),
+		mapping.WithResolveChains(false),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(m.Rules) > 0 {
+		fmt.Fprintf(utils.Stdout(c.Ctx), "Rules: %d wildcard rules are ignored, only repositories of explicit rows are cloned\n", len(m.Rules))
+	}
+
+	var (
+		repoDirs   = make([]string, 0, len(m.Entries))
+		entries    = make(map[string]mapping.Entry, len(m.Entries))
+		remoteUrls = make(map[string]string, len(m.Entries))
+	)
+	for _, e := range m.Entries {
+		repoDir, err := cloneDir(c.RootPath, e.OldUrl)
+		if err != nil {
+			return err
+		}
+		if _, found := entries[repoDir]; found {
+			// duplicates are reported by mapping validate
+			continue
+		}
+		repoDirs = append(repoDirs, repoDir)
+		entries[repoDir] = e
+		remoteUrls[repoDir] = e.OldUrl
+	}
+
+	fleetOptions := c.Config.FleetOptions()
+	fleetOptions.RemoteUrls = remoteUrls
+
+	executor := fleet.NewExecutor(fleetOptions)
+	results := executor.Run(c.Ctx, repoDirs, func(ctx context.Context, repoDir string) error {
+		cloned, err := clone(ctx, entries[repoDir], repoDir, c.Config.RemoteName)
+		if fleet.IsSkipped(err) {
+			fmt.Fprintf(utils.Stdout(ctx), "Skipped %s: %v\n", repoDir, err)
+		} else if err != nil {
+			fmt.Fprintf(utils.Stderr(ctx), "Error: failed to clone repo %s: %v\n", repoDir, err)
+		} else if cloned {
+			fmt.Fprintf(utils.Stdout(ctx), "Successfully cloned %s\n", repoDir)
+		} else {
+			fmt.Fprintf(utils.Stdout(ctx), "Successfully fetched %s\n", repoDir)
+		}
+		return err
+	})
+
+	err = results.PrintSummary(os.Stdout, c.RootPath)
+	if err != nil {
+		return err
+	}
+
+	if c.Config.Report != "" {
+		err = report.New(cmd.Name(), c.RootPath, started, results).WriteFile(c.Config.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = results.Err()
+	if err != nil {
+		// the errors of the repositories were already printed
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return err
+	}
+	return nil
+}
+
+// cloneDir returns the directory <root>/<host>/<project>/<repo> of the clone of the git url.
+func cloneDir(rootPath, gitUrl string) (string, error) {
+	rel, err := utils.ToRepoPath(gitUrl)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rootPath, filepath.FromSlash(rel)), nil
+}
+
+// clone clones the old url of the entry or fetches the remote in case the repository was already cloned.
+func clone(ctx context.Context, entry mapping.Entry, repoDir, remoteName string) (cloned bool, err error) {
+	if entry.Skip {
+		return false, fleet.ErrSkipped
+	}
+
+	_, found, err := utils.Exists(repoDir)
+	if err != nil {
+		return false, err
+	}
+
+	if !found {
+		return true, fleet.RunStep(ctx, "clone", func() error {
+			return utils.GitClone(ctx, entry.OldUrl, repoDir, remoteName, entry.DefaultBranch, 0)
+		})
+	}
+
+	_, found, err = utils.Exists(filepath.Join(repoDir, ".git"))
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, fmt.Errorf("%s already exists and is no git repository", repoDir)
+	}
+
+	// the remote url was already changed in case the repository was committed
+	remoteUrl, err := utils.GitRemoteUrl(ctx, repoDir, remoteName)
+	if err != nil {
+		return false, err
+	}
+	if !sameRepository(remoteUrl, entry.OldUrl) && !sameRepository(remoteUrl, entry.NewUrl) {
+		return false, fmt.Errorf("%s already exists with the remote url %s instead of %s", repoDir, remoteUrl, entry.OldUrl)
+	}
+
+	return false, fleet.RunStep(ctx, "fetch", func() error {
+		return utils.GitFetch(ctx, repoDir, remoteName)
+	})
+}
+
+// sameRepository returns true in case both urls are different forms of the same repository.
+func sameRepository(a, b string) bool {
+	ia, err := utils.RepoIdentity(a)
+	if err != nil {
+		return false
+	}
+	ib, err := utils.RepoIdentity(b)
+	if err != nil {
+		return false
+	}
+	return ia == ib
+}
@@ -0,0 +1,44 @@
+package clone
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/internal/gittest"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClone(t *testing.T) {
+	ctx := context.Background()
+	oldDir := gittest.NewRemote(t)
+	gittest.Commit(t, oldDir, "main", map[string]string{"go.mod": "module example.com/app\n"})
+
+	entry, err := mapping.NewEntry(oldDir, gittest.NewRemote(t))
+	require.NoError(t, err)
+
+	repoDir := filepath.Join(t.TempDir(), "app")
+	cloned, err := clone(ctx, entry, repoDir, "origin")
+	require.NoError(t, err)
+	require.True(t, cloned)
+	require.FileExists(t, filepath.Join(repoDir, "go.mod"))
+
+	// existing clones are fetched
+	gittest.Commit(t, oldDir, "main", map[string]string{"README.md": "app"})
+	cloned, err = clone(ctx, entry, repoDir, "origin")
+	require.NoError(t, err)
+	require.False(t, cloned)
+	require.Equal(t, gittest.Git(t, oldDir, "rev-parse", "main"), gittest.Git(t, repoDir, "rev-parse", "origin/main"))
+
+	// clones of other repositories are never touched
+	other, err := mapping.NewEntry(gittest.NewRemote(t), gittest.NewRemote(t))
+	require.NoError(t, err)
+	_, err = clone(ctx, other, repoDir, "origin")
+	require.ErrorContains(t, err, "already exists with the remote url")
+
+	entry.Skip = true
+	_, err = clone(ctx, entry, repoDir, "origin")
+	require.ErrorIs(t, err, fleet.ErrSkipped)
+}
@@ -0,0 +1,85 @@
+package clone
+
+import (
+	"errors"
+
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
+)
+
+type CloneConfig struct {
+	CSVPath string `koanf:"csv" short:"c" description:"path to mapping file (.csv, .yaml, .yml or .json)"`
+
+	Comma     string `koanf:"separator" short:"s" description:"column separator character in csv"`
+	OldColumn string `koanf:"old" short:"o" description:"column name or index (starting with 0) containing the old [git] url"`
+	NewColumn string `koanf:"new" short:"n" description:"column name or index (starting with 0) containing the new [git] url"`
+
+	RemoteName string `koanf:"remote" short:"r" description:"name of the remote url"`
+
+	Jobs      string `koanf:"jobs" short:"j" description:"maximum number of repositories that are processed in parallel, 0 uses the number of CPUs"`
+	HostJobs  string `koanf:"host.jobs" description:"',' separated list of per remote host limits of repositories that are processed in parallel, e.g. git.company.com=2,*=4"`
+	KeepGoing bool   `koanf:"keep.going" description:"process all repositories even if a repository failed, otherwise repositories that were not started yet are skipped after the first failure"`
+	Report    string `koanf:"report" description:"write a run report to the file path, the format is selected by the extension: .json, .xml (JUnit) or .md (Markdown)"`
+
+	comma rune
+	fleet fleet.Options
+
+	oldIdx int
+	newIdx int
+}
+
+func (c *CloneConfig) Validate() error {
+	if len(c.CSVPath) == 0 {
+		return errors.New("mapping file path is empty")
+	}
+
+	if c.RemoteName == "" {
+		return errors.New("remote name is empty")
+	}
+
+	var err error
+	c.fleet, err = fleet.ParseOptions(c.Jobs, c.HostJobs, c.RemoteName)
+	if err != nil {
+		return err
+	}
+	c.fleet.KeepGoing = c.KeepGoing
+
+	if c.Report != "" {
+		err = report.CheckPath(c.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	comma := ([]rune(c.Comma))
+	if len(comma) == 0 {
+		return errors.New("column separator is empty")
+	}
+	c.comma = comma[0]
+
+	oldIdx, newIdx, err := mapping.ColumnIndexes(c.CSVPath, c.comma, c.OldColumn, c.NewColumn)
+	if err != nil {
+		return err
+	}
+	c.oldIdx = oldIdx
+	c.newIdx = newIdx
+
+	return nil
+}
+
+func (c *CloneConfig) CommaRune() rune {
+	return c.comma
+}
+
+func (c *CloneConfig) OldColumnIndex() int {
+	return c.oldIdx
+}
+
+func (c *CloneConfig) NewColumnIndex() int {
+	return c.newIdx
+}
+
+func (c *CloneConfig) FleetOptions() fleet.Options {
+	return c.fleet
+}
@@ -7,10 +7,13 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
-	"sync"
+	"slices"
+	"time"
 
 	"github.com/jxsl13/module-migration/config"
-	"github.com/jxsl13/module-migration/csv"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
 	"github.com/jxsl13/module-migration/utils"
 	"github.com/spf13/cobra"
 )
@@ -49,12 +52,17 @@ type commitContext struct {
 
 func (c *commitContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
 	c.Config = &CommitConfig{
-		RemoteName: "origin",
-		BranchName: "chore/module-migration",
-		CSVPath:    "./mapping.csv",
-		Comma:      ";", // default separator
-		OldColumn:  "0",
-		NewColumn:  "1",
+		ResolveChains: true,
+		RemoteName:    "origin",
+		LegacyRemote:  "legacy-origin",
+		Jobs:          "0",
+		KeepGoing:     true,
+		Dirty:         utils.DirtyAbort,
+		BranchName:    "chore/module-migration",
+		CSVPath:       "./mapping.csv",
+		Comma:         ";", // default separator
+		OldColumn:     "0",
+		NewColumn:     "1",
 	}
 
 	runParser := config.RegisterFlags(c.Config, true, cmd)
@@ -71,63 +79,98 @@ func (c *commitContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, arg
 }
 
 func (c *commitContext) RunE(cmd *cobra.Command, args []string) (err error) {
-	gitUrlMap, _, err := csv.NewReplacerFromCSV(
+	started := time.Now()
+	m, err := mapping.Load(
 		c.Config.CSVPath,
 		c.Config.OldColumnIndex(),
 		c.Config.NewColumnIndex(),
 		c.Config.CommaRune(),
+		mapping.WithResolveChains(c.Config.ResolveChains),
+		mapping.WithOutput(utils.Stdout(c.Ctx)),
+		mapping.WithModulePaths(c.Config.ModulePathRules()),
 	)
 	if err != nil {
 		return err
 	}
 
-	targetUrlMap := make(map[string]bool, len(gitUrlMap))
-	for _, v := range gitUrlMap {
-		targetUrlMap[v] = true
-	}
-
 	repoDirs, err := utils.FindRepoDirs(c.RootPath)
 	if err != nil {
 		return fmt.Errorf("failed to find git folders: %w", err)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(repoDirs))
-	for _, repoDir := range repoDirs {
-		go func(repoDir string) {
-			defer wg.Done()
-			err := commit(c.Ctx, gitUrlMap, targetUrlMap, repoDir, c.Config.RemoteName, c.Config.BranchName)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: failed to commit repo %s: %v\n", repoDir, err)
-			} else {
-				fmt.Printf("Successfully committed %s\n", repoDir)
-			}
-		}(repoDir)
+	resolved, err := m.ExpandRepos(c.Ctx, repoDirs, c.Config.RemoteName)
+	if err != nil {
+		return err
+	}
+
+	fleetOptions := c.Config.FleetOptions()
+	fleetOptions.Command = cmd.Name()
+	fleetOptions.State, err = fleet.LoadState(c.Ctx, c.RootPath)
+	if err != nil {
+		return err
+	}
+
+	executor := fleet.NewExecutor(fleetOptions)
+	results := executor.Run(c.Ctx, repoDirs, func(ctx context.Context, repoDir string) error {
+		err := commit(ctx, resolved, fleetOptions.State, repoDir, c.Config.RemoteName, c.Config.LegacyRemote, c.Config.BranchName, c.Config.Dirty, c.Config.Worktree)
+		if fleet.IsSkipped(err) {
+			fmt.Fprintf(utils.Stdout(ctx), "Skipped %s: %v\n", repoDir, err)
+		} else if err != nil {
+			fmt.Fprintf(utils.Stderr(ctx), "Error: failed to commit repo %s: %v\n", repoDir, err)
+		} else {
+			fmt.Fprintf(utils.Stdout(ctx), "Successfully committed %s\n", repoDir)
+		}
+		return err
+	})
+
+	err = results.PrintSummary(os.Stdout, c.RootPath)
+	if err != nil {
+		return err
+	}
+
+	if c.Config.Report != "" {
+		err = report.New(cmd.Name(), c.RootPath, started, results).WriteFile(c.Config.Report)
+		if err != nil {
+			return err
+		}
 	}
-	wg.Wait()
 
+	err = results.Err()
+	if err != nil {
+		// the errors of the repositories were already printed
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return err
+	}
 	return nil
 }
 
 func commit(ctx context.Context,
-	gitUrlMap map[string]string,
-	targetUrlMap map[string]bool,
+	resolved *mapping.Resolved,
+	state *fleet.State,
 	repoDir,
-	remoteName string,
-	targetBranch string) error {
+	remoteName,
+	legacyRemote string,
+	targetBranch string,
+	dirty string,
+	worktree bool) (err error) {
 
 	repoUrl, err := utils.GitRemoteUrl(ctx, repoDir, remoteName)
 	if err != nil {
 		return err
 	}
 
-	targetUrl, found := gitUrlMap[repoUrl]
+	var targetUrl string
+	entry, found := resolved.Lookup(repoUrl)
 	if found {
-		err = utils.GitChangeRemoteUrl(ctx, repoDir, remoteName, targetBranch)
-		if err != nil {
-			return err
+		if entry.Skip {
+			return fleet.ErrSkipped
+		}
+		targetUrl = entry.NewUrl
+	} else if entry, found = resolved.LookupTarget(repoUrl); found {
+		if entry.Skip {
+			return fleet.ErrSkipped
 		}
-	} else if targetUrlMap[repoUrl] {
 		// nothing todo, already target url
 		targetUrl = repoUrl
 	} else {
@@ -139,6 +182,21 @@ func commit(ctx context.Context,
 	if err != nil {
 		return err
 	}
+
+	// the remote is switched after the local changes were checked, so that a refused commit keeps the old remote
+	switchRemote := func() error {
+		if repoUrl == targetUrl {
+			return nil
+		}
+		return fleet.RunStep(ctx, "remote", func() error {
+			return utils.GitSwitchRemote(ctx, repoDir, remoteName, legacyRemote, targetUrl)
+		})
+	}
+
+	if worktree {
+		return commitWorktree(ctx, state, repoDir, remoteName, targetBranch, entry, switchRemote)
+	}
+
 	err = utils.GitRefreshIndex(ctx, repoDir)
 	if err != nil {
 		return fmt.Errorf("failed to refresh git repo index: %w", err)
@@ -149,20 +207,50 @@ func commit(ctx context.Context,
 		return err
 	}
 
+	// local changes that do not belong to the migration must not be committed
+	err = fleet.Measure(ctx, "local changes", func() error {
+		unrelated, err := unrelatedChanges(ctx, state, repoDir, repoDir)
+		if err != nil {
+			return err
+		}
+		return utils.HandleLocalChanges(ctx, repoDir, dirty, unrelated)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = switchRemote()
+	if err != nil {
+		return err
+	}
+
 	if currentBranch != targetBranch {
 		// create a new branch with the current changes
-		err = utils.GitCheckoutNewBranch(ctx, repoDir, targetBranch)
+		err = fleet.RunStep(ctx, "branch", func() error {
+			return utils.GitCheckoutNewBranch(ctx, repoDir, targetBranch)
+		})
 		if err != nil {
 			return err
 		}
 		defer func() {
 			if err != nil {
-				e := utils.GitCheckoutBranch(ctx, repoDir, currentBranch)
+				// the branch and its commit are removed, so they must be created again
+				e := fleet.ResetSteps(ctx)
+				if e != nil {
+					err = errors.Join(err, e)
+				}
+
+				e = utils.GitCheckoutBranch(ctx, repoDir, currentBranch)
 				if e != nil {
 					err = errors.Join(err, e)
 					return
 				}
 
+				e = utils.RestoreLocalChanges(ctx, repoDir)
+				if e != nil {
+					err = errors.Join(err, e)
+				}
+
 				e = utils.GitDeleteBranch(ctx, repoDir, targetBranch)
 				if e != nil {
 					err = errors.Join(err, e)
@@ -177,26 +265,158 @@ func commit(ctx context.Context,
 		}()
 	}
 
-	err = utils.GitAddAll(ctx, repoDir)
+	err = fleet.RunStep(ctx, "commit", func() error {
+		err := utils.GitAddAll(ctx, repoDir)
+		if err != nil {
+			return err
+		}
+
+		// max commit subject length is 50 characters
+		// max body length is 75 characters
+		return utils.GitCommit(ctx, repoDir, "chore: Go module migration")
+	})
 	if err != nil {
 		return err
 	}
 
-	// max commit subject length is 50 characters
-	// max body length is 75 characters
-	err = utils.GitCommit(ctx, repoDir, "chore: Go module migration")
+	err = fleet.RunStep(ctx, "push", func() error {
+		return utils.GitPushUpstream(ctx, repoDir, remoteName, targetBranch)
+	})
 	if err != nil {
 		return err
 	}
 
-	err = utils.GitPushUpstream(ctx, repoDir, remoteName, targetBranch)
+	err = fleet.RunStep(ctx, "pr", func() error {
+		return utils.CreateGithubPullRequest(ctx, repoDir, utils.PullRequest{
+			Title:     "chore: Go module migration",
+			Base:      entry.DefaultBranch,
+			Reviewers: entry.Reviewers,
+			Labels:    entry.Labels,
+		})
+	})
 	if err != nil {
 		return err
 	}
 
-	err = utils.CreateGithubPullRequest(ctx, repoDir, "chore: Go module migration")
+	return restoreLocalChanges(ctx, repoDir)
+}
+
+// commitWorktree commits and pushes the migration worktree of the repository and removes the worktree afterwards.
+// The checked out working tree of the repository is not touched. The remote is switched with switchRemote
+// after the worktree was checked for local changes.
+func commitWorktree(ctx context.Context,
+	state *fleet.State,
+	repoDir,
+	remoteName,
+	targetBranch string,
+	entry mapping.Entry,
+	switchRemote func() error) error {
+
+	worktreeDir, err := utils.GitWorktreeDir(ctx, repoDir)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	_, found, err := utils.Exists(worktreeDir)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no migration worktree found in %s, use migrate --worktree first", worktreeDir)
+	}
+
+	// nobody but the migration is expected to change the worktree
+	err = fleet.Measure(ctx, "local changes", func() error {
+		unrelated, err := unrelatedChanges(ctx, state, repoDir, worktreeDir)
+		if err != nil {
+			return err
+		}
+		return utils.HandleLocalChanges(ctx, worktreeDir, utils.DirtyAbort, unrelated)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = switchRemote()
+	if err != nil {
+		return err
+	}
+
+	err = fleet.RunStep(ctx, "commit", func() error {
+		err := utils.GitAddAll(ctx, worktreeDir)
+		if err != nil {
+			return err
+		}
+		return utils.GitCommit(ctx, worktreeDir, "chore: Go module migration")
+	})
+	if err != nil {
+		return err
+	}
+
+	err = fleet.RunStep(ctx, "push", func() error {
+		return utils.GitPushUpstream(ctx, worktreeDir, remoteName, targetBranch)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = fleet.RunStep(ctx, "pr", func() error {
+		return utils.CreateGithubPullRequest(ctx, worktreeDir, utils.PullRequest{
+			Title:     "chore: Go module migration",
+			Base:      entry.DefaultBranch,
+			Reviewers: entry.Reviewers,
+			Labels:    entry.Labels,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return fleet.RunStep(ctx, "remove worktree", func() error {
+		return utils.GitWorktreeRemove(ctx, repoDir, worktreeDir)
+	})
+}
+
+// errNoMigration is returned in case the working tree has changes, but no successful migration is recorded.
+var errNoMigration = errors.New("no successful migration recorded in the state file")
+
+// unrelatedChanges returns the changed files of the working tree that were not touched by the migrate subcommand.
+// The migration state is kept per repository directory, the working tree may be the migration worktree.
+// Changes without a recorded successful migration, e.g. after a dry run, a deleted state file or a manual
+// migration, are refused, because the migration changes cannot be told apart from local changes and
+// must neither be committed as local changes nor stashed.
+func unrelatedChanges(ctx context.Context, state *fleet.State, repoDir, workDir string) ([]string, error) {
+	changed, err := utils.GitChangedFiles(ctx, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the progress of the migrate subcommand is kept in the same state file
+	migrated, found := state.Files("migrate", repoDir)
+	if !found {
+		if len(changed) > 0 {
+			return nil, fmt.Errorf("%w for %s, %d changed files cannot be told apart from local changes: re-run migrate", errNoMigration, repoDir, len(changed))
+		}
+		return nil, nil
+	}
+
+	unrelated := make([]string, 0)
+	for _, f := range changed {
+		if !slices.Contains(migrated, f) {
+			unrelated = append(unrelated, f)
+		}
+	}
+	return unrelated, nil
+}
+
+// restoreLocalChanges restores stashed local changes on the branch they were made on.
+func restoreLocalChanges(ctx context.Context, repoDir string) error {
+	found, err := utils.HasStashedLocalChanges(ctx, repoDir)
+	if err != nil || !found {
+		return err
+	}
+
+	return fleet.RunStep(ctx, "restore local changes", func() error {
+		return utils.RestoreLocalChanges(ctx, repoDir)
+	})
 }
@@ -0,0 +1,47 @@
+package commit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/internal/gittest"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitRefusesUnrecordedMigration(t *testing.T) {
+	ctx := context.Background()
+	oldDir := gittest.NewRemote(t)
+	gittest.Commit(t, oldDir, "main", map[string]string{"go.mod": "module example.com/app\n"})
+	newDir := gittest.NewRemote(t)
+
+	entry, err := mapping.NewEntry(oldDir, newDir)
+	require.NoError(t, err)
+	resolved, err := (&mapping.Mapping{Entries: []mapping.Entry{entry}}).Expand(ctx, nil, nil)
+	require.NoError(t, err)
+
+	rootPath := t.TempDir()
+	repoDir := filepath.Join(rootPath, "app")
+	gittest.Git(t, "", "clone", oldDir, repoDir)
+	state, err := fleet.LoadState(ctx, rootPath)
+	require.NoError(t, err)
+
+	// migrate did not record the touched files, e.g. because it was a dry run
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "go.mod"), []byte("module example.com/local\n"), 0666))
+
+	for _, dirty := range []string{utils.DirtyAbort, utils.DirtyStash} {
+		err = commit(ctx, resolved, state, repoDir, "origin", "legacy", "chore/module-migration", dirty, false)
+		require.ErrorIs(t, err, errNoMigration)
+
+		// the refused commit keeps the old remote and neither stashes nor commits the changes
+		require.Equal(t, oldDir, gittest.Git(t, repoDir, "remote", "get-url", "origin"))
+		require.Equal(t, "origin", gittest.Git(t, repoDir, "remote"))
+		require.Equal(t, "main", gittest.Git(t, repoDir, "branch", "--show-current"))
+		require.Equal(t, "M go.mod", gittest.Git(t, repoDir, "status", "--porcelain"))
+		require.Empty(t, gittest.Git(t, repoDir, "stash", "list"))
+	}
+}
@@ -2,22 +2,43 @@ package commit
 
 import (
 	"errors"
-	"strconv"
+	"strings"
 
-	"github.com/jxsl13/module-migration/csv"
+	"github.com/jxsl13/module-migration/defaults"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
+	"github.com/jxsl13/module-migration/utils"
 )
 
 type CommitConfig struct {
-	CSVPath string `koanf:"csv" short:"c" description:"path to csv mapping file"`
+	CSVPath string `koanf:"csv" short:"c" description:"path to mapping file (.csv, .yaml, .yml or .json)"`
 
 	Comma     string `koanf:"separator" short:"s" description:"column separator character in csv"`
 	OldColumn string `koanf:"old" short:"o" description:"column name or index (starting with 0) containing the old [git] url"`
 	NewColumn string `koanf:"new" short:"n" description:"column name or index (starting with 0) containing the new [git] url"`
 
-	RemoteName string `koanf:"remote" short:"r" description:"name of the remote url"`
-	BranchName string `koanf:"branch" short:"b" description:"name of the branch that should be crated for the changes, if empty no branch migration will be executed with git"`
+	ResolveChains bool   `koanf:"resolve.chains" description:"resolve chained mappings (a -> b, b -> c) to their final target (a -> c)"`
+	ModuleRules   string `koanf:"module.rules"`
 
-	comma rune
+	RemoteName   string `koanf:"remote" short:"r" description:"name of the remote url"`
+	LegacyRemote string `koanf:"legacy.remote" description:"new name of the remote with the old url after the remote was switched to the new url, if empty the old url is replaced"`
+	BranchName   string `koanf:"branch" short:"b" description:"name of the branch that should be crated for the changes, if empty no branch migration will be executed with git"`
+
+	Jobs        string `koanf:"jobs" short:"j" description:"maximum number of repositories that are processed in parallel, 0 uses the number of CPUs"`
+	HostJobs    string `koanf:"host.jobs" description:"',' separated list of per remote host limits of repositories that are processed in parallel, e.g. git.company.com=2,*=4"`
+	KeepGoing   bool   `koanf:"keep.going" description:"process all repositories even if a repository failed, otherwise repositories that were not started yet are skipped after the first failure"`
+	Report      string `koanf:"report" description:"write a run report to the file path, the format is selected by the extension: .json, .xml (JUnit) or .md (Markdown)"`
+	RetryFailed bool   `koanf:"retry.failed" description:"only process repositories that failed in a previous run, the state of every run is kept in the root directory or in its git directory in case it is part of a repository"`
+	Force       bool   `koanf:"force" description:"process all repositories from scratch instead of resuming the previous run"`
+
+	Worktree bool `koanf:"worktree" description:"commit the migration worktree created by migrate --worktree instead of the checked out working tree and remove the worktree after the push"`
+
+	Dirty string `koanf:"dirty" description:"handling of uncommitted or untracked changes that were not made by the migrate subcommand: 'abort' refuses to commit the repository, 'stash' stashes the changes and restores them after the migration commit"`
+
+	comma       rune
+	fleet       fleet.Options
+	moduleRules utils.ModulePathRules
 
 	oldIdx int
 	newIdx int
@@ -25,44 +46,81 @@ type CommitConfig struct {
 
 func (c *CommitConfig) Validate() error {
 	if len(c.CSVPath) == 0 {
-		return errors.New("csv file path is empty")
+		return errors.New("mapping file path is empty")
 	}
 
 	if c.RemoteName == "" {
 		return errors.New("remote name is empty")
 	}
-	comma := ([]rune(c.Comma))
-	if len(comma) == 0 {
-		return errors.New("column separator is empty")
+
+	if c.LegacyRemote == c.RemoteName {
+		return errors.New("legacy remote name must differ from the remote name")
+	}
+
+	var err error
+	c.fleet, err = fleet.ParseOptions(c.Jobs, c.HostJobs, c.RemoteName)
+	if err != nil {
+		return err
+	}
+	c.fleet.KeepGoing = c.KeepGoing
+
+	err = utils.CheckDirtyMode(c.Dirty)
+	if err != nil {
+		return err
+	}
+
+	if c.Worktree && c.BranchName == "" {
+		return errors.New("--worktree requires a branch name")
 	}
-	c.comma = comma[0]
 
-	oldIdx, errOld := strconv.Atoi(c.OldColumn)
-	newIdx, errNew := strconv.Atoi(c.NewColumn)
+	switch {
+	case c.RetryFailed && c.Force:
+		return errors.New("--retry-failed and --force cannot be used together")
+	case c.RetryFailed:
+		c.fleet.Resume = fleet.RetryFailed
+	case c.Force:
+		c.fleet.Resume = fleet.Force
+	}
 
-	if errOld != nil || errNew != nil {
-		header, err := csv.Header(c.CSVPath, c.comma)
+	if c.Report != "" {
+		err = report.CheckPath(c.Report)
 		if err != nil {
 			return err
 		}
+	}
 
-		for idx, col := range header {
-			if col == c.OldColumn {
-				oldIdx = idx
-			}
+	c.moduleRules, err = utils.ParseModulePathRules(strings.Split(c.ModuleRules, defaults.ListSeparator))
+	if err != nil {
+		return err
+	}
 
-			if col == c.NewColumn {
-				newIdx = idx
-			}
-		}
+	comma := ([]rune(c.Comma))
+	if len(comma) == 0 {
+		return errors.New("column separator is empty")
 	}
+	c.comma = comma[0]
 
+	oldIdx, newIdx, err := mapping.ColumnIndexes(c.CSVPath, c.comma, c.OldColumn, c.NewColumn)
+	if err != nil {
+		return err
+	}
 	c.oldIdx = oldIdx
 	c.newIdx = newIdx
 
 	return nil
 }
 
+// Descriptions returns the descriptions of the flags that are shared with other subcommands.
+func (c *CommitConfig) Descriptions() map[string]string {
+	return map[string]string{
+		"module.rules": utils.ModulePathRulesDescription,
+	}
+}
+
+func (c *CommitConfig) ModulePathRules() utils.ModulePathRules {
+	return c.moduleRules
+}
+
 func (c *CommitConfig) CommaRune() rune {
 	return c.comma
 }
@@ -74,3 +132,7 @@ func (c *CommitConfig) OldColumnIndex() int {
 func (c *CommitConfig) NewColumnIndex() int {
 	return c.newIdx
 }
+
+func (c *CommitConfig) FleetOptions() fleet.Options {
+	return c.fleet
+}
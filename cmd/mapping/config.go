@@ -0,0 +1,74 @@
+package mapping
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jxsl13/module-migration/defaults"
+	model "github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/utils"
+)
+
+type MappingConfig struct {
+	CSVPath string `koanf:"csv" short:"c" description:"path to mapping file (.csv, .yaml, .yml or .json)"`
+
+	Comma     string `koanf:"separator" short:"s" description:"column separator character in csv"`
+	OldColumn string `koanf:"old" short:"o" description:"column name or index (starting with 0) containing the old [git] url"`
+	NewColumn string `koanf:"new" short:"n" description:"column name or index (starting with 0) containing the new [git] url"`
+
+	ModuleRules string `koanf:"module.rules"`
+
+	comma       rune
+	moduleRules utils.ModulePathRules
+
+	oldIdx int
+	newIdx int
+}
+
+func (c *MappingConfig) Validate() error {
+	if len(c.CSVPath) == 0 {
+		return errors.New("mapping file path is empty")
+	}
+	comma := ([]rune(c.Comma))
+	if len(comma) == 0 {
+		return errors.New("column separator is empty")
+	}
+	c.comma = comma[0]
+
+	oldIdx, newIdx, err := model.ColumnIndexes(c.CSVPath, c.comma, c.OldColumn, c.NewColumn)
+	if err != nil {
+		return err
+	}
+	c.oldIdx = oldIdx
+	c.newIdx = newIdx
+
+	c.moduleRules, err = utils.ParseModulePathRules(strings.Split(c.ModuleRules, defaults.ListSeparator))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Descriptions returns the descriptions of the flags that are shared with other subcommands.
+func (c *MappingConfig) Descriptions() map[string]string {
+	return map[string]string{
+		"module.rules": utils.ModulePathRulesDescription,
+	}
+}
+
+func (c *MappingConfig) ModulePathRules() utils.ModulePathRules {
+	return c.moduleRules
+}
+
+func (c *MappingConfig) CommaRune() rune {
+	return c.comma
+}
+
+func (c *MappingConfig) OldColumnIndex() int {
+	return c.oldIdx
+}
+
+func (c *MappingConfig) NewColumnIndex() int {
+	return c.newIdx
+}
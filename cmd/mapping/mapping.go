@@ -0,0 +1,21 @@
+package mapping
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewMappingCmd() *cobra.Command {
+	// cmd groups all mapping file related subcommands
+	cmd := &cobra.Command{
+		Use:   "mapping",
+		Short: "inspect and validate mapping files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+
+	cmd.AddCommand(NewValidateCmd())
+	cmd.AddCommand(NewShowCmd())
+	return cmd
+}
@@ -0,0 +1,94 @@
+package mapping
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/jxsl13/module-migration/config"
+	model "github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/spf13/cobra"
+)
+
+func NewShowCmd() *cobra.Command {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+
+	showContext := showContext{
+		Ctx: ctx,
+	}
+
+	// cmd represents the run command
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "prints the old and new module paths that are derived from the git urls of every mapping row",
+		Args:  cobra.NoArgs,
+		RunE:  showContext.RunE,
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+
+			cancel()
+			return nil
+		},
+	}
+
+	// register flags but defer parsing and validation of the final values
+	cmd.PreRunE = showContext.PreRunE(cmd)
+
+	return cmd
+}
+
+type showContext struct {
+	Ctx    context.Context
+	Config *MappingConfig
+}
+
+func (c *showContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
+	c.Config = &MappingConfig{
+		CSVPath:   "./mapping.csv",
+		Comma:     ";", // default separator
+		OldColumn: "0",
+		NewColumn: "1",
+	}
+
+	runParser := config.RegisterFlags(c.Config, true, cmd)
+
+	return func(cmd *cobra.Command, args []string) error {
+		return runParser()
+	}
+}
+
+func (c *showContext) RunE(cmd *cobra.Command, args []string) (err error) {
+	m, loadErr := model.Load(
+		c.Config.CSVPath,
+		c.Config.OldColumnIndex(),
+		c.Config.NewColumnIndex(),
+		c.Config.CommaRune(),
+		model.WithResolveChains(false),
+		model.WithModulePaths(c.Config.ModulePathRules()),
+	)
+	if m == nil {
+		return loadErr
+	}
+
+	// invalid rows are not shown
+	for _, e := range unwrapJoined(loadErr) {
+		fmt.Fprintln(os.Stderr, e)
+	}
+
+	tw := tabwriter.NewWriter(utils.Stdout(c.Ctx), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tOLD URL\tOLD MODULE\tNEW URL\tNEW MODULE")
+	for _, e := range m.Entries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", e.Line, e.OldUrl, e.OldModule, e.NewUrl, e.NewModule)
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if len(m.Rules) > 0 {
+		fmt.Fprintf(utils.Stdout(c.Ctx), "\nRules: the module paths of %d wildcard rules are derived when they match a repository\n", len(m.Rules))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package mapping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/jxsl13/module-migration/config"
+	model "github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/spf13/cobra"
+)
+
+func NewValidateCmd() *cobra.Command {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+
+	validateContext := validateContext{
+		Ctx: ctx,
+	}
+
+	// cmd represents the run command
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "checks the mapping file for duplicates, collisions, chains, cycles, invalid module paths and prefix overlaps and exits with a non-zero exit code in case of any problems",
+		Args:  cobra.NoArgs,
+		RunE:  validateContext.RunE,
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+
+			cancel()
+			return nil
+		},
+	}
+
+	// register flags but defer parsing and validation of the final values
+	cmd.PreRunE = validateContext.PreRunE(cmd)
+
+	return cmd
+}
+
+type validateContext struct {
+	Ctx    context.Context
+	Config *MappingConfig
+}
+
+func (c *validateContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
+	c.Config = &MappingConfig{
+		CSVPath:   "./mapping.csv",
+		Comma:     ";", // default separator
+		OldColumn: "0",
+		NewColumn: "1",
+	}
+
+	runParser := config.RegisterFlags(c.Config, true, cmd)
+
+	return func(cmd *cobra.Command, args []string) error {
+		return runParser()
+	}
+}
+
+func (c *validateContext) RunE(cmd *cobra.Command, args []string) (err error) {
+	m, loadErr := model.Load(
+		c.Config.CSVPath,
+		c.Config.OldColumnIndex(),
+		c.Config.NewColumnIndex(),
+		c.Config.CommaRune(),
+		model.WithResolveChains(false),
+		model.WithModulePaths(c.Config.ModulePathRules()),
+	)
+	if m == nil {
+		return loadErr
+	}
+
+	// invalid rows do not prevent the validation of all other rows
+	loadErrs := unwrapJoined(loadErr)
+	for _, e := range loadErrs {
+		fmt.Fprintln(os.Stderr, e)
+	}
+
+	problems := m.Validate()
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", m.Source, p)
+	}
+
+	total := len(loadErrs) + len(problems)
+	if total > 0 {
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return fmt.Errorf("found %d problems in %s", total, m.Source)
+	}
+
+	fmt.Fprintf(utils.Stdout(c.Ctx), "Mapping is valid: %d entries, %d rules\n", len(m.Entries), len(m.Rules))
+	return nil
+}
+
+func unwrapJoined(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -4,26 +4,31 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 
-	"github.com/jxsl13/module-migration/csv"
 	"github.com/jxsl13/module-migration/defaults"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
 	"github.com/jxsl13/module-migration/utils"
 )
 
 type MigrateConfig struct {
 	// shared flags
-	CSVPath string `koanf:"csv" short:"c" description:"path to csv mapping file"`
+	CSVPath string `koanf:"csv" short:"c" description:"path to mapping file (.csv, .yaml, .yml or .json)"`
 
 	Comma     string `koanf:"separator" short:"s" description:"column separator character in csv"`
 	OldColumn string `koanf:"old" short:"o" description:"column name or index (starting with 0) containing the old [git] url"`
 	NewColumn string `koanf:"new" short:"n" description:"column name or index (starting with 0) containing the new [git] url"`
 
+	ResolveChains bool   `koanf:"resolve.chains" description:"resolve chained mappings (a -> b, b -> c) to their final target (a -> c)"`
+	ModuleRules   string `koanf:"module.rules"`
+
 	RemoteName string `koanf:"remote" short:"r" description:"name of the remote url"`
 	BranchName string `koanf:"branch" short:"b" description:"name of the branch that should be crated for the changes, if empty no branch migration will be executed with git"`
 
-	comma rune
+	comma       rune
+	moduleRules utils.ModulePathRules
 
 	oldIdx int
 	newIdx int
@@ -32,15 +37,42 @@ type MigrateConfig struct {
 	Include         string `koanf:"include" short:"i" description:"',' separated list of include file paths matching regular expression"`
 	Exclude         string `koanf:"exclude" short:"e" description:"',' separated list of exclude file paths matching regular expression"`
 	AdditionalFiles string `koanf:"copy" description:"moves specified files or directories into your repository (, separated)"`
+	DryRun          bool   `koanf:"dry.run" description:"print a unified diff of all changes per repository without modifying any files"`
+	Dirty           string `koanf:"dirty" description:"handling of uncommitted or untracked local changes: 'abort' refuses to process the repository, 'stash' stashes the changes and restores them after the migration commit"`
+	Worktree        bool   `koanf:"worktree" description:"migrate every repository in a temporary git worktree of the migration branch instead of the checked out working tree, the worktree is removed by the commit subcommand after the push"`
+	KeepFailed      bool   `koanf:"keep.failed" description:"keep the changes of repositories whose migration failed for debugging instead of restoring their previous state"`
+	ModulePath      string `koanf:"module.path" description:"source of the module path of each repository: 'remote' derives it from the remote url, 'declared' keeps the path declared in go.mod unless the mapping requests a new one"`
+	VersionFallback string `koanf:"version.fallback" description:"version of a mapped dependency whose pinned version does not exist at the new module path: 'latest-patch' selects the latest patch release of the same minor version, 'latest' the latest release and 'fail' fails the repository"`
+	Vanity          string `koanf:"vanity" description:"',' separated list of vanity import path prefix to git host path prefix mappings, e.g. go.company.com=git.company.com/project"`
+
+	Jobs        string `koanf:"jobs" short:"j" description:"maximum number of repositories that are processed in parallel, 0 uses the number of CPUs"`
+	HostJobs    string `koanf:"host.jobs" description:"',' separated list of per remote host limits of repositories that are processed in parallel, e.g. git.company.com=2,*=4"`
+	KeepGoing   bool   `koanf:"keep.going" description:"process all repositories even if a repository failed, otherwise repositories that were not started yet are skipped after the first failure"`
+	Report      string `koanf:"report" description:"write a run report to the file path, the format is selected by the extension: .json, .xml (JUnit) or .md (Markdown)"`
+	RetryFailed bool   `koanf:"retry.failed" description:"only process repositories that failed in a previous run, the state of every run is kept in the root directory or in its git directory in case it is part of a repository"`
+	Force       bool   `koanf:"force" description:"process all repositories from scratch instead of resuming the previous run and reset existing migration branches even if they have unpushed commits"`
 
 	include    []*regexp.Regexp
 	exclude    []*regexp.Regexp
 	additional []string
+	vanity     mapping.VanityTable
+	fleet      fleet.Options
 }
 
+const (
+	ModulePathRemote   = "remote"
+	ModulePathDeclared = "declared"
+)
+
+const (
+	VersionFallbackLatestPatch = "latest-patch"
+	VersionFallbackLatest      = "latest"
+	VersionFallbackFail        = "fail"
+)
+
 func (c *MigrateConfig) Validate() error {
 	if len(c.CSVPath) == 0 {
-		return errors.New("csv file path is empty")
+		return errors.New("mapping file path is empty")
 	}
 	comma := ([]rune(c.Comma))
 	if len(comma) == 0 {
@@ -48,59 +80,109 @@ func (c *MigrateConfig) Validate() error {
 	}
 	c.comma = comma[0]
 
-	oldIdx, errOld := strconv.Atoi(c.OldColumn)
-	newIdx, errNew := strconv.Atoi(c.NewColumn)
+	oldIdx, newIdx, err := mapping.ColumnIndexes(c.CSVPath, c.comma, c.OldColumn, c.NewColumn)
+	if err != nil {
+		return err
+	}
+	c.oldIdx = oldIdx
+	c.newIdx = newIdx
 
-	if errOld != nil || errNew != nil {
-		header, err := csv.Header(c.CSVPath, c.comma)
-		if err != nil {
-			return err
-		}
+	c.moduleRules, err = utils.ParseModulePathRules(strings.Split(c.ModuleRules, defaults.ListSeparator))
+	if err != nil {
+		return err
+	}
 
-		for idx, col := range header {
-			if col == c.OldColumn {
-				oldIdx = idx
-			}
+	c.include, err = compileRegexps("include", strings.Split(c.Include, defaults.ListSeparator))
+	if err != nil {
+		return err
+	}
 
-			if col == c.NewColumn {
-				newIdx = idx
-			}
-		}
+	c.exclude, err = compileRegexps("exclude", strings.Split(c.Exclude, defaults.ListSeparator))
+	if err != nil {
+		return err
 	}
 
-	c.oldIdx = oldIdx
-	c.newIdx = newIdx
+	err = utils.CheckDirtyMode(c.Dirty)
+	if err != nil {
+		return err
+	}
 
-	ss := strings.Split(c.Include, defaults.ListSeparator)
-	c.include = make([]*regexp.Regexp, 0, len(ss))
-	for _, s := range ss {
-		r, err := regexp.Compile(s)
-		if err != nil {
-			return fmt.Errorf("invalid include regex: %q: %w", s, err)
-		}
-		c.include = append(c.include, r)
+	if c.Worktree && c.BranchName == "" {
+		return errors.New("--worktree requires a branch name")
 	}
 
-	ss = strings.Split(c.Exclude, defaults.ListSeparator)
-	c.exclude = make([]*regexp.Regexp, 0, len(ss))
-	for _, s := range ss {
-		r, err := regexp.Compile(s)
+	switch c.ModulePath {
+	case ModulePathRemote, ModulePathDeclared:
+	default:
+		return fmt.Errorf("invalid module path source %q, expected one of %q or %q", c.ModulePath, ModulePathRemote, ModulePathDeclared)
+	}
+
+	switch c.VersionFallback {
+	case VersionFallbackLatestPatch, VersionFallbackLatest, VersionFallbackFail:
+	default:
+		return fmt.Errorf("invalid version fallback %q, expected one of %q, %q or %q", c.VersionFallback, VersionFallbackLatestPatch, VersionFallbackLatest, VersionFallbackFail)
+	}
+
+	c.vanity, err = mapping.ParseVanityTable(strings.Split(c.Vanity, defaults.ListSeparator))
+	if err != nil {
+		return err
+	}
+
+	c.fleet, err = fleet.ParseOptions(c.Jobs, c.HostJobs, c.RemoteName)
+	if err != nil {
+		return err
+	}
+	c.fleet.KeepGoing = c.KeepGoing
+
+	switch {
+	case c.RetryFailed && c.Force:
+		return errors.New("--retry-failed and --force cannot be used together")
+	case c.RetryFailed:
+		c.fleet.Resume = fleet.RetryFailed
+	case c.Force:
+		c.fleet.Resume = fleet.Force
+	}
+
+	if c.Report != "" {
+		err = report.CheckPath(c.Report)
 		if err != nil {
-			return fmt.Errorf("invalid exclude regex: %q: %w", s, err)
+			return err
 		}
-		c.exclude = append(c.exclude, r)
 	}
 
-	c.additional = strings.Split(c.AdditionalFiles, defaults.ListSeparator)
-
-	for _, filename := range c.additional {
-		_, found, err := utils.Exists(filename)
+	c.additional = make([]string, 0, 1)
+	for _, filename := range strings.Split(c.AdditionalFiles, defaults.ListSeparator) {
+		if filename == "" {
+			continue
+		}
+		err = checkAdditional(filename)
 		if err != nil {
 			return err
 		}
-		if !found {
-			return fmt.Errorf("additional file or directory not found: %s", filename)
+		c.additional = append(c.additional, filename)
+	}
+	return nil
+}
+
+func compileRegexps(kind string, ss []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(ss))
+	for _, s := range ss {
+		r, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s regex: %q: %w", kind, s, err)
 		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func checkAdditional(filename string) error {
+	_, found, err := utils.Exists(filename)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("additional file or directory not found: %s", filename)
 	}
 	return nil
 }
@@ -117,6 +199,21 @@ func (c *MigrateConfig) Additional() []string {
 	return c.additional
 }
 
+func (c *MigrateConfig) VanityTable() mapping.VanityTable {
+	return c.vanity
+}
+
+// Descriptions returns the descriptions of the flags that are shared with other subcommands.
+func (c *MigrateConfig) Descriptions() map[string]string {
+	return map[string]string{
+		"module.rules": utils.ModulePathRulesDescription,
+	}
+}
+
+func (c *MigrateConfig) ModulePathRules() utils.ModulePathRules {
+	return c.moduleRules
+}
+
 func (c *MigrateConfig) CommaRune() rune {
 	return c.comma
 }
@@ -128,3 +225,7 @@ func (c *MigrateConfig) OldColumnIndex() int {
 func (c *MigrateConfig) NewColumnIndex() int {
 	return c.newIdx
 }
+
+func (c *MigrateConfig) FleetOptions() fleet.Options {
+	return c.fleet
+}
@@ -2,20 +2,28 @@ package migrate
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/jxsl13/module-migration/config"
-	"github.com/jxsl13/module-migration/csv"
 	"github.com/jxsl13/module-migration/defaults"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
 	"github.com/jxsl13/module-migration/utils"
 	"github.com/spf13/cobra"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
 func NewMigrateCmd() *cobra.Command {
@@ -48,19 +56,33 @@ type migrateContext struct {
 	Ctx      context.Context
 	Config   *MigrateConfig
 	RootPath string `koanf:"root.path" short:"" description:"root search directory"`
+
+	// repeat processes repositories again that already succeeded in a previous run
+	repeat bool
 }
 
-func (c *migrateContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
-	c.Config = &MigrateConfig{
-		RemoteName: "origin",
-		BranchName: "chore/module-migration",
-		CSVPath:    "./mapping.csv",
-		Comma:      ";", // default separator
-		OldColumn:  "0",
-		NewColumn:  "1",
-		Include:    strings.Join(defaults.Include, defaults.ListSeparator),
-		Exclude:    strings.Join(defaults.Exclude, defaults.ListSeparator),
+// newMigrateConfig returns the default configuration that is shared by all subcommands that migrate repositories.
+func newMigrateConfig() *MigrateConfig {
+	return &MigrateConfig{
+		ResolveChains:   true,
+		RemoteName:      "origin",
+		BranchName:      "chore/module-migration",
+		CSVPath:         "./mapping.csv",
+		Comma:           ";", // default separator
+		OldColumn:       "0",
+		NewColumn:       "1",
+		Include:         strings.Join(defaults.Include, defaults.ListSeparator),
+		Exclude:         strings.Join(defaults.Exclude, defaults.ListSeparator),
+		ModulePath:      ModulePathRemote,
+		VersionFallback: VersionFallbackLatestPatch,
+		Jobs:            "0",
+		KeepGoing:       true,
+		Dirty:           utils.DirtyAbort,
 	}
+}
+
+func (c *migrateContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
+	c.Config = newMigrateConfig()
 
 	runParser := config.RegisterFlags(c.Config, true, cmd)
 
@@ -76,12 +98,22 @@ func (c *migrateContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, ar
 }
 
 func (c *migrateContext) RunE(cmd *cobra.Command, args []string) (err error) {
+	return c.run(cmd, "migrate", "migrated", migrateRepo)
+}
 
-	_, moduleMap, err := csv.NewReplacerFromCSV(
+// run migrates all Go repositories of the root directory with the passed function.
+// The verb and its past tense are used in the status lines of the repositories.
+func (c *migrateContext) run(cmd *cobra.Command, verb, past string, fn func(ctx context.Context, opts migrateOptions) error) (err error) {
+	started := time.Now()
+	m, err := mapping.Load(
 		c.Config.CSVPath,
 		c.Config.OldColumnIndex(),
 		c.Config.NewColumnIndex(),
 		c.Config.CommaRune(),
+		mapping.WithResolveChains(c.Config.ResolveChains),
+		mapping.WithOutput(utils.Stdout(c.Ctx)),
+		mapping.WithModulePaths(c.Config.ModulePathRules()),
+		mapping.WithVanity(c.Config.VanityTable()),
 	)
 	if err != nil {
 		return err
@@ -92,149 +124,926 @@ func (c *migrateContext) RunE(cmd *cobra.Command, args []string) (err error) {
 		return fmt.Errorf("failed to find git folders: %w", err)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(repoDirs))
-	for _, repoDir := range repoDirs {
-		go func(repoDir string) {
-			defer wg.Done()
-			err := migrateRepo(
-				c.Ctx,
-				repoDir,
-				c.Config.RemoteName,
-				c.Config.BranchName,
-				c.Config.Additional(),
-				c.Config.ExcludeRegex(),
-				c.Config.IncludeRegex(),
-				moduleMap,
-			)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: failed to migrate repo %s: %v\n", repoDir, err)
-			} else {
-				fmt.Printf("Successfully migrated %s\n", repoDir)
-			}
-		}(repoDir)
+	resolved, err := m.ExpandRepos(c.Ctx, repoDirs, c.Config.RemoteName)
+	if err != nil {
+		return err
 	}
-	wg.Wait()
 
+	moduleMap := c.moduleMap(resolved)
+
+	fleetOptions := c.Config.FleetOptions()
+	if !c.Config.DryRun {
+		// resume interrupted runs
+		fleetOptions.Command = cmd.Name()
+		if c.repeat && fleetOptions.Resume == fleet.Resume {
+			// the previous success of a periodic command must not skip the next run
+			fleetOptions.Resume = fleet.Repeat
+		}
+		fleetOptions.State, err = fleet.LoadState(c.Ctx, c.RootPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	executor := fleet.NewExecutor(fleetOptions)
+	results := executor.Run(c.Ctx, repoDirs, func(ctx context.Context, repoDir string) error {
+		opts, err := c.migrateOptions(ctx, repoDir, resolved, moduleMap)
+		if err == nil {
+			err = fn(ctx, opts)
+		}
+		c.printStatus(ctx, verb, past, repoDir, err)
+		return err
+	})
+
+	err = results.PrintSummary(os.Stdout, c.RootPath)
+	if err != nil {
+		return err
+	}
+
+	if c.Config.Report != "" {
+		err = report.New(cmd.Name(), c.RootPath, started, results).WriteFile(c.Config.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = results.Err()
+	if err != nil {
+		// the errors of the repositories were already printed
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return err
+	}
 	return nil
 }
 
-func migrateRepo(ctx context.Context,
-	repoDir,
-	remoteName string,
-	targetBranch string,
-	additionalFiles []string,
-	exclude, include []*regexp.Regexp,
-	moduleMap map[string]string,
-) (err error) {
+// moduleMap returns the module path mapping of the resolved mapping.
+func (c *migrateContext) moduleMap(resolved *mapping.Resolved) map[string]string {
+	// vanity import paths of requirements follow the module paths of their git host paths,
+	// but declared vanity import paths are only changed when explicitly requested
+	all := c.Config.ModulePath != ModulePathDeclared
+	return mergeMaps(resolved.Modules, resolved.VanityModules(c.Config.VanityTable(), all))
+}
+
+// printStatus prints the outcome of a single repository.
+func (c *migrateContext) printStatus(ctx context.Context, verb, past, repoDir string, err error) {
+	if fleet.IsSkipped(err) {
+		fmt.Fprintf(utils.Stdout(ctx), "Skipped %s: %v\n", repoDir, err)
+	} else if err != nil {
+		fmt.Fprintf(utils.Stderr(ctx), "Error: failed to %s repo %s: %v\n", verb, repoDir, err)
+	} else if c.Config.DryRun {
+		fmt.Fprintf(utils.Stdout(ctx), "Dry run: no files changed in %s\n", repoDir)
+	} else {
+		fmt.Fprintf(utils.Stdout(ctx), "Successfully %s %s\n", past, repoDir)
+	}
+}
+
+type migrateOptions struct {
+	RepoDir         string
+	RemoteName      string
+	TargetBranch    string
+	DefaultBranch   string
+	Reviewers       []string
+	Labels          []string
+	AdditionalFiles []string
+	Exclude         []*regexp.Regexp
+	Include         []*regexp.Regexp
+	ModuleMap       map[string]string
+	ModulePath      string
+	// NewModule is the new module path of the mapping entry of the repository,
+	// empty in case the repository is not mapped
+	NewModule       string
+	ModuleRules     utils.ModulePathRules
+	VersionFallback string
+	DryRun          bool
+	KeepFailed      bool
+	Dirty           string
+	Worktree        bool
+	// Force resets an existing migration branch even if it has unpushed commits
+	Force bool
+}
 
-	// pull before changing anything
-	_ = utils.GitPull(ctx, repoDir)
-	goMod := filepath.Join(repoDir, "go.mod")
-	missingDependencies, additionalImports, err := migrateGoMod(ctx, repoDir, remoteName, goMod, moduleMap)
+// migrateOptions applies the repository specific mapping options to the global configuration
+func (c *migrateContext) migrateOptions(ctx context.Context, repoDir string, resolved *mapping.Resolved, moduleMap map[string]string) (opts migrateOptions, err error) {
+	opts = migrateOptions{
+		RepoDir:         repoDir,
+		RemoteName:      c.Config.RemoteName,
+		TargetBranch:    c.Config.BranchName,
+		AdditionalFiles: c.Config.Additional(),
+		Exclude:         c.Config.ExcludeRegex(),
+		Include:         c.Config.IncludeRegex(),
+		ModuleMap:       moduleMap,
+		ModulePath:      c.Config.ModulePath,
+		ModuleRules:     c.Config.ModulePathRules(),
+		VersionFallback: c.Config.VersionFallback,
+		DryRun:          c.Config.DryRun,
+		KeepFailed:      c.Config.KeepFailed,
+		Dirty:           c.Config.Dirty,
+		Worktree:        c.Config.Worktree,
+		Force:           c.Config.Force,
+	}
+
+	repoUrl, err := utils.GitRemoteUrl(ctx, repoDir, c.Config.RemoteName)
 	if err != nil {
-		return fmt.Errorf("failed to migrate go mod: %s: %w", goMod, err)
+		return opts, err
+	}
+
+	entry, found := resolved.LookupAny(repoUrl)
+	if !found {
+		return opts, nil
+	}
+
+	if entry.Skip {
+		return opts, fleet.ErrSkipped
+	}
+	opts.NewModule = entry.NewModule
+	opts.DefaultBranch = entry.DefaultBranch
+	opts.Reviewers = entry.Reviewers
+	opts.Labels = entry.Labels
+
+	if len(entry.Include) > 0 {
+		opts.Include, err = compileRegexps("include", entry.Include)
+		if err != nil {
+			return opts, err
+		}
 	}
-	replacer := utils.NewReplacer(mergeMaps(moduleMap, additionalImports))
-	exclude = append(exclude, regexp.MustCompile(`go\.mod$`), regexp.MustCompile(`go\.sum$`))
-	_, err = utils.ReplaceInDir(repoDir, exclude, include, replacer)
+
+	if len(entry.Exclude) > 0 {
+		opts.Exclude, err = compileRegexps("exclude", entry.Exclude)
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	for _, filename := range entry.Copy {
+		err = checkAdditional(filename)
+		if err != nil {
+			return opts, err
+		}
+		opts.AdditionalFiles = append(opts.AdditionalFiles[:len(opts.AdditionalFiles):len(opts.AdditionalFiles)], filename)
+	}
+	return opts, nil
+}
+
+func migrateRepo(ctx context.Context, opts migrateOptions) (err error) {
+	var (
+		repoDir = opts.RepoDir
+		dryRun  = opts.DryRun
+	)
+
+	// the snapshot restores the repository in case the migration fails, worktrees are removed instead
+	var snapshot *utils.Snapshot
+	if !dryRun && opts.Worktree {
+		// the checked out working tree, its branch and its index are never touched
+		var worktreeDir string
+		worktreeDir, err = checkoutWorktree(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer func(opts migrateOptions) {
+			err = finishWorktree(ctx, opts, worktreeDir, err)
+		}(opts)
+
+		// all following steps are executed in the worktree
+		opts.RepoDir = worktreeDir
+		repoDir = worktreeDir
+	} else if !dryRun {
+		// changes of a previous run of an interrupted migration are not local changes
+		if !fleet.Resumed(ctx) {
+			err = fleet.Measure(ctx, "local changes", func() error {
+				files, err := utils.GitChangedFiles(ctx, repoDir)
+				if err != nil {
+					return err
+				}
+				return utils.HandleLocalChanges(ctx, repoDir, opts.Dirty, files)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// the migration branch is removed or reset together with the repository
+		branches := make([]string, 0, 1)
+		if opts.TargetBranch != "" {
+			branches = append(branches, opts.TargetBranch)
+		}
+		// a resumed run restores the repository as it was before the first run, not the half migrated one
+		snapshot, err = fleet.RunStepValue(ctx, "snapshot", func() (*utils.Snapshot, error) {
+			return utils.TakeSnapshot(ctx, repoDir, branches...)
+		})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = finishSnapshot(ctx, snapshot, opts.KeepFailed, err)
+		}()
+
+		if opts.TargetBranch == "" {
+			// pull before changing anything
+			_ = fleet.RunStep(ctx, "pull", func() error {
+				return utils.GitPull(ctx, repoDir)
+			})
+		} else {
+			err = checkoutMigrationBranch(ctx, opts)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var (
+		modules []goModule
+		renames map[string]string
+		changes = make([]utils.FileChange, 0, 64)
+	)
+	err = fleet.Measure(ctx, "go.mod", func() (err error) {
+		modules, err = findModules(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		// requirements of other modules of the same repository follow their new module paths
+		renames = moduleRenames(modules)
+		moduleMap := mergeMaps(opts.ModuleMap, renames)
+		for idx, m := range modules {
+			goMod := filepath.Join(m.Dir, "go.mod")
+			change, pinned, err := migrateGoMod(ctx, goMod, m.NewPath, moduleMap)
+			if err != nil {
+				return fmt.Errorf("failed to migrate go mod: %s: %w", goMod, err)
+			}
+			if change.Changed() {
+				changes = append(changes, change)
+			}
+			modules[idx].Pinned = pinned
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the longest module path is replaced first, so nested modules keep their own new module paths
+	replacer := utils.NewReplacer(mergeMaps(opts.ModuleMap, renames))
+	exclude := append(opts.Exclude[:len(opts.Exclude):len(opts.Exclude)], regexp.MustCompile(`go\.mod$`), regexp.MustCompile(`go\.sum$`))
+	var (
+		replaced  []utils.FileChange
+		ambiguous []utils.AmbiguousMatch
+	)
+	err = fleet.Measure(ctx, "replace", func() (err error) {
+		replaced, ambiguous, err = utils.ComputeReplaceInDir(repoDir, exclude, opts.Include, replacer)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	changes = append(changes, replaced...)
+
+	for _, a := range ambiguous {
+		rel, _ := filepath.Rel(repoDir, a.Path)
+		fmt.Fprintf(utils.Stdout(ctx), "Ambiguous: %s:%d: refused to rewrite %s in %s\n", rel, a.Line, a.Old, a.Token)
+	}
+
+	if dryRun {
+		for _, af := range opts.AdditionalFiles {
+			copied, err := utils.ComputeCopy(af, repoDir)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, copied...)
+		}
+
+		var sb strings.Builder
+		for _, c := range changes {
+			sb.WriteString(c.Diff(repoDir))
+		}
+		touched(ctx, repoDir, changes)
+		for _, m := range modules {
+			for _, dep := range m.Pinned {
+				fmt.Fprintf(&sb, "Dependency: would require in %s: %s\n", m.NewPath, dep)
+			}
+		}
+		fmt.Fprint(utils.Stdout(ctx), sb.String())
+		return nil
+	}
+
+	// the pinned requirements and the previous contents of the written files cannot be computed again
+	// from the written files when the run is resumed
+	written, err := fleet.RunStepValue(ctx, "write", func() (writeResult, error) {
+		backups, err := backup(ctx, snapshot, changes)
+		if err != nil {
+			return writeResult{}, err
+		}
+		for _, c := range changes {
+			err := c.Write()
+			if err != nil {
+				return writeResult{}, err
+			}
+		}
+
+		pinned := make(map[string][]module.Version, len(modules))
+		for _, m := range modules {
+			pinned[m.Rel] = m.Pinned
+		}
+		return writeResult{Pinned: pinned, Backups: backups}, nil
+	})
 	if err != nil {
 		return err
 	}
+	restoreBackups(snapshot, written.Backups)
+	touched(ctx, repoDir, changes)
+	for idx, m := range modules {
+		modules[idx].Pinned = written.Pinned[m.Rel]
+	}
 
-	for _, af := range additionalFiles {
-		err = utils.Copy(ctx, af, repoDir)
+	if len(opts.AdditionalFiles) > 0 {
+		backups, err := fleet.RunStepValue(ctx, "copy", func() (backups map[string][]byte, err error) {
+			for _, af := range opts.AdditionalFiles {
+				copied, err := utils.ComputeCopy(af, repoDir)
+				if err != nil {
+					return nil, err
+				}
+				backups, err = backup(ctx, snapshot, copied)
+				if err != nil {
+					return nil, err
+				}
+				err = utils.Copy(ctx, af, repoDir)
+				if err != nil {
+					return nil, err
+				}
+				touched(ctx, repoDir, copied)
+			}
+			return backups, nil
+		})
 		if err != nil {
 			return err
 		}
+		restoreBackups(snapshot, backups)
 	}
 
-	for _, dep := range missingDependencies {
-		fmt.Printf("Dependency: updating: %s\n", dep)
-		err = utils.GoGet(ctx, repoDir, fmt.Sprintf("%s@latest", dep))
+	// every module is resolved, tidied and built on its own
+	for _, m := range modules {
+		err = finishModule(ctx, m, opts.VersionFallback)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the working tree was clean before the migration, so all changes belong to the migration,
+	// including go.sum and formatting changes
+	changed, err := utils.GitChangedFiles(ctx, repoDir)
+	if err != nil {
+		return err
+	}
+	fleet.Touched(ctx, changed...)
+	return nil
+}
+
+// goModule is a Go module of a repository.
+type goModule struct {
+	// Dir is the directory of the go.mod file
+	Dir string
+	// Rel is the slash separated directory relative to the repository, "." for the root module
+	Rel     string
+	Path    string
+	NewPath string
+
+	// Pinned are the mapped requirements that still need to be resolved
+	Pinned []module.Version
+}
+
+// step returns the name of a step of the module, steps of the root module have no suffix.
+func (m goModule) step(name string) string {
+	if m.Rel == "." {
+		return name
+	}
+	return name + " " + m.Rel
+}
+
+// findModules returns all Go modules of the repository including their module paths after the migration.
+func findModules(ctx context.Context, opts migrateOptions) ([]goModule, error) {
+	moduleDirs, err := utils.FindGoModDirs(opts.RepoDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(moduleDirs) == 0 {
+		return nil, fmt.Errorf("no go.mod found in %s", opts.RepoDir)
+	}
+
+	modules := make([]goModule, 0, len(moduleDirs))
+	for _, moduleDir := range moduleDirs {
+		goMod := filepath.Join(moduleDir, "go.mod")
+		data, err := os.ReadFile(goMod)
+		if err != nil {
+			return nil, err
+		}
+
+		declared := modfile.ModulePath(data)
+		if declared == "" {
+			return nil, fmt.Errorf("%s has no module directive", goMod)
+		}
+
+		rel, err := filepath.Rel(opts.RepoDir, moduleDir)
+		if err != nil {
+			return nil, err
+		}
+
+		modules = append(modules, goModule{
+			Dir:  moduleDir,
+			Rel:  filepath.ToSlash(rel),
+			Path: declared,
+		})
+	}
+
+	root := declaredRoot(modules)
+	for idx, m := range modules {
+		modules[idx].NewPath, err = expectedModulePath(ctx, m.Path, m.Rel, root, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return modules, nil
+}
+
+// declaredRoot returns the declared module path of the repository root without a major version suffix.
+// Repositories without a root module derive it from a nested module whose path ends with its directory.
+// The root is empty in case none of the module paths matches its directory.
+func declaredRoot(modules []goModule) string {
+	for _, m := range modules {
+		if m.Rel == "." {
+			prefix, _, _ := module.SplitPathVersion(m.Path)
+			return prefix
+		}
+	}
+
+	for _, m := range modules {
+		prefix, _, _ := module.SplitPathVersion(m.Path)
+		if root, found := strings.CutSuffix(prefix, "/"+m.Rel); found {
+			return root
+		}
+	}
+	return ""
+}
+
+// moduleRenames returns the changed module paths of the modules.
+func moduleRenames(modules []goModule) map[string]string {
+	renames := make(map[string]string, len(modules))
+	for _, m := range modules {
+		if m.Path != m.NewPath {
+			renames[m.Path] = m.NewPath
+		}
+	}
+	return renames
+}
+
+// finishModule resolves the pinned requirements of the module, fixes its go.sum file, formats and builds it.
+func finishModule(ctx context.Context, m goModule, fallback string) error {
+	for _, dep := range m.Pinned {
+		err := fleet.RunStep(ctx, m.step("resolve "+dep.Path), func() error {
+			return resolveVersion(ctx, m.Dir, dep, fallback)
+		})
 		if err != nil {
 			return err
 		}
 	}
 
 	// fix go.sum file
-	err = utils.GoModTidy(ctx, repoDir)
+	err := fleet.RunStep(ctx, m.step("tidy"), func() error {
+		return utils.GoModTidy(ctx, m.Dir)
+	})
 	if err != nil {
 		return err
 	}
 
-	err = utils.GoFmt(ctx, repoDir)
+	err = fleet.RunStep(ctx, m.step("fmt"), func() error {
+		return utils.GoFmt(ctx, m.Dir)
+	})
 	if err != nil {
 		return err
 	}
 
-	return utils.GoBuildAll(ctx, repoDir)
+	return fleet.RunStep(ctx, m.step("build"), func() error {
+		return utils.GoBuildAll(ctx, m.Dir)
+	})
 }
 
-func migrateGoMod(ctx context.Context, repoDir, remoteName, goModFilePath string, moduleMap map[string]string) ([]string, map[string]string, error) {
+// checkoutMigrationBranch creates the migration branch from the tip of the remote default branch,
+// so the migration never builds on top of a stale or a feature branch.
+func checkoutMigrationBranch(ctx context.Context, opts migrateOptions) error {
+	var (
+		repoDir    = opts.RepoDir
+		remoteName = opts.RemoteName
+	)
 
-	data, err := os.ReadFile(goModFilePath)
+	err := fleet.RunStep(ctx, "fetch", func() error {
+		return utils.GitFetch(ctx, repoDir, remoteName)
+	})
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 
-	modFile, err := modfile.Parse(goModFilePath, data, nil)
+	return fleet.RunStep(ctx, "branch", func() error {
+		defaultBranch := opts.DefaultBranch
+		if defaultBranch == "" {
+			defaultBranch, err = utils.GitGetDefaultBranch(ctx, repoDir, remoteName)
+			if err != nil {
+				return err
+			}
+		}
+
+		// an existing migration branch is deleted and created again, which must not lose unpushed commits
+		if !opts.Force && utils.GitExistsBranch(ctx, repoDir, "refs/heads/"+opts.TargetBranch) {
+			err = utils.GitCheckBranchPushed(ctx, repoDir, opts.TargetBranch, protectedRefs(opts, defaultBranch)...)
+			if err != nil {
+				return unpushedHint(err)
+			}
+		}
+
+		fmt.Fprintf(utils.Stdout(ctx), "Branch: creating %s from %s/%s\n", opts.TargetBranch, remoteName, defaultBranch)
+		err = utils.GitCheckoutRemoteBranch(ctx, repoDir, remoteName, defaultBranch)
+		if err != nil {
+			return err
+		}
+		return utils.GitCheckoutNewBranch(ctx, repoDir, opts.TargetBranch)
+	})
+}
+
+// checkoutWorktree creates the migration branch from the tip of the remote default branch in the
+// migration worktree of the repository and returns the worktree directory.
+func checkoutWorktree(ctx context.Context, opts migrateOptions) (string, error) {
+	var (
+		repoDir    = opts.RepoDir
+		remoteName = opts.RemoteName
+	)
+
+	worktreeDir, err := utils.GitWorktreeDir(ctx, repoDir)
+	if err != nil {
+		return "", err
+	}
+
+	err = fleet.RunStep(ctx, "fetch", func() error {
+		return utils.GitFetch(ctx, repoDir, remoteName)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = fleet.RunStep(ctx, "worktree", func() error {
+		defaultBranch := opts.DefaultBranch
+		if defaultBranch == "" {
+			defaultBranch, err = utils.GitGetDefaultBranch(ctx, repoDir, remoteName)
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(utils.Stdout(ctx), "Worktree: creating %s from %s/%s in %s\n", opts.TargetBranch, remoteName, defaultBranch, worktreeDir)
+		err = utils.GitWorktreeAdd(ctx, repoDir, worktreeDir, opts.TargetBranch, remoteName+"/"+defaultBranch, opts.Force, protectedRefs(opts, defaultBranch)...)
+		return unpushedHint(err)
+	})
+	if err != nil {
+		return "", err
+	}
+	return worktreeDir, nil
+}
+
+// protectedRefs returns the remote branches that must contain all commits of an existing migration branch before it is reset.
+func protectedRefs(opts migrateOptions, defaultBranch string) []string {
+	return []string{
+		"refs/remotes/" + opts.RemoteName + "/" + opts.TargetBranch,
+		"refs/remotes/" + opts.RemoteName + "/" + defaultBranch,
+	}
+}
+
+// unpushedHint tells the user how to resolve a migration branch with unpushed commits.
+func unpushedHint(err error) error {
+	if errors.Is(err, utils.ErrUnpushedCommits) {
+		return fmt.Errorf("%w, push or delete the branch or use --force", err)
+	}
+	return err
+}
+
+// finishWorktree removes the worktree and the migration branch in case the migration failed.
+func finishWorktree(ctx context.Context, opts migrateOptions, worktreeDir string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if opts.KeepFailed {
+		fmt.Fprintf(utils.Stdout(ctx), "Keep failed: the failed migration is kept in the worktree %s\n", worktreeDir)
+		return err
+	}
+
+	fmt.Fprintf(utils.Stdout(ctx), "Rollback: removing worktree %s\n", worktreeDir)
+	rollbackErr := fleet.Measure(ctx, "rollback", func() error {
+		err := utils.GitWorktreeRemove(ctx, opts.RepoDir, worktreeDir)
+		if err != nil {
+			return err
+		}
+		return utils.GitDeleteBranch(ctx, opts.RepoDir, opts.TargetBranch)
+	})
+	if rollbackErr != nil {
+		return errors.Join(err, fmt.Errorf("rollback failed: %w", rollbackErr))
+	}
+
+	// the completed steps were rolled back
+	return errors.Join(err, fleet.ResetSteps(ctx))
+}
+
+// writeResult is the persisted result of the write step.
+type writeResult struct {
+	// Pinned are the pinned requirements by module directory
+	Pinned map[string][]module.Version
+	// Backups are the backups of the snapshot after the files were written
+	Backups map[string][]byte
+}
+
+// backup records the untracked and ignored files of the changes in the snapshot before they are written,
+// because the snapshot cannot restore them otherwise. All backups of the snapshot are returned, so they
+// can be persisted together with the step that writes the files. Worktrees have no snapshot.
+func backup(ctx context.Context, snapshot *utils.Snapshot, changes []utils.FileChange) (map[string][]byte, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	err := snapshot.Backup(ctx, changes)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read go mod file: %w", err)
+		return nil, err
+	}
+	return maps.Clone(snapshot.Backups), nil
+}
+
+// restoreBackups adds the persisted backups of a step that was completed in a previous run to the snapshot.
+func restoreBackups(snapshot *utils.Snapshot, backups map[string][]byte) {
+	if snapshot == nil {
+		return
+	}
+	if snapshot.Backups == nil {
+		snapshot.Backups = make(map[string][]byte, len(backups))
+	}
+	for f, data := range backups {
+		if _, found := snapshot.Backups[f]; !found {
+			snapshot.Backups[f] = data
+		}
+	}
+}
+
+// finishSnapshot restores the repository in case the migration failed and releases the snapshot.
+func finishSnapshot(ctx context.Context, snapshot *utils.Snapshot, keepFailed bool, err error) error {
+	if err == nil {
+		return snapshot.Release(ctx)
+	}
+
+	if keepFailed {
+		fmt.Fprintf(utils.Stdout(ctx), "Keep failed: the state before the migration is kept in %s of %s, stashed local changes are restored after the migration commit\n", utils.SnapshotRef, snapshot.RepoDir)
+		return err
+	}
+
+	fmt.Fprintf(utils.Stdout(ctx), "Rollback: restoring %s\n", snapshot.RepoDir)
+	rollbackErr := fleet.Measure(ctx, "rollback", func() error {
+		return snapshot.Restore(ctx)
+	})
+	if rollbackErr != nil {
+		return errors.Join(err, fmt.Errorf("rollback failed: %w", rollbackErr))
+	}
+
+	// there will be no migration commit
+	rollbackErr = utils.RestoreLocalChanges(ctx, snapshot.RepoDir)
+	if rollbackErr != nil {
+		err = errors.Join(err, rollbackErr)
 	}
 
-	url, err := utils.GitRemoteUrl(ctx, repoDir, remoteName)
+	// the completed steps were rolled back
+	return errors.Join(err, fleet.ResetSteps(ctx))
+}
+
+// touched records the changed files relative to the repository directory.
+func touched(ctx context.Context, repoDir string, changes []utils.FileChange) {
+	for _, c := range changes {
+		rel, err := filepath.Rel(repoDir, c.Path)
+		if err != nil {
+			rel = c.Path
+		}
+		fleet.Touched(ctx, filepath.ToSlash(rel))
+	}
+}
+
+// migrateGoMod computes the new go.mod content without writing it.
+// The mapped requirements keep their versions and indirect markers, they are returned as pinned requirements
+// that still need to be resolved at their new module paths.
+func migrateGoMod(ctx context.Context, goModFilePath, modulePath string, moduleMap map[string]string) (change utils.FileChange, pinned []module.Version, err error) {
+	data, err := os.ReadFile(goModFilePath)
 	if err != nil {
-		return nil, nil, err
+		return change, nil, err
 	}
 
-	expectedModuleUrl, err := utils.ToModuleUrl(url)
+	modFile, err := modfile.Parse(goModFilePath, data, nil)
 	if err != nil {
-		return nil, nil, err
+		return change, nil, fmt.Errorf("failed to read go mod file: %w", err)
 	}
 
 	// map module name
-	additionalImports := make(map[string]string, 1)
 	moduleName := modFile.Module.Mod.Path
-	if moduleName != expectedModuleUrl {
-		fmt.Printf("Module: fix: %s -> %s\n", moduleName, expectedModuleUrl)
-		modFile.AddModuleStmt(expectedModuleUrl)
-		additionalImports[moduleName] = expectedModuleUrl
+	if moduleName != modulePath {
+		fmt.Fprintf(utils.Stdout(ctx), "Module: fix: %s -> %s\n", moduleName, modulePath)
+		modFile.AddModuleStmt(modulePath)
 	} else {
-		fmt.Printf("Module: nothing to change for %s\n", moduleName)
+		fmt.Fprintf(utils.Stdout(ctx), "Module: nothing to change for %s\n", moduleName)
 	}
 
-	// map dependencies
-	foundDependencies := make([]string, 0, 1)
+	// map dependencies, also major version suffixes and nested modules
+	replacer := utils.NewReplacer(moduleMap)
+	pinned = make([]module.Version, 0, 1)
+
 	for _, req := range modFile.Require {
-		targetModulePath, found := moduleMap[req.Mod.Path]
-		if !found {
-			fmt.Printf("Dependency: nothing to do: %s\n", req.Mod.Path)
+		targetModulePath := replacer.Replace(req.Mod.Path)
+		if targetModulePath == req.Mod.Path {
+			fmt.Fprintf(utils.Stdout(ctx), "Dependency: nothing to do: %s\n", req.Mod.Path)
 			continue
 		}
 
-		fmt.Printf("Found dependency mapping: %s -> %s\n", req.Mod.Path, targetModulePath)
-		err = modFile.DropRequire(req.Mod.Path)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to drop old dependency: %s: %w", req.Mod.Path, err)
-		}
+		fmt.Fprintf(utils.Stdout(ctx), "Found dependency mapping: %s -> %s@%s\n", req.Mod, targetModulePath, req.Mod.Version)
+		renameToken(req.Syntax.Token, req.Mod.Path, targetModulePath)
+		req.Mod.Path = targetModulePath
+
+		pinned = append(pinned, req.Mod)
+	}
 
-		foundDependencies = append(foundDependencies, targetModulePath)
+	err = migrateDirectives(ctx, modFile, replacer)
+	if err != nil {
+		return change, nil, fmt.Errorf("failed to migrate directives of %s: %w", goModFilePath, err)
 	}
 
+	// replaced requirements, e.g. of other modules of the same repository, are not resolved at their module path
+	pinned = slices.DeleteFunc(pinned, func(dep module.Version) bool {
+		for _, r := range modFile.Replace {
+			if r.Old.Path == dep.Path && (r.Old.Version == "" || r.Old.Version == dep.Version) {
+				fmt.Fprintf(utils.Stdout(ctx), "Dependency: keep %s, it is replaced by %s\n", dep, r.New)
+				return true
+			}
+		}
+		return false
+	})
+
 	modFile.Cleanup()
 
-	data, err = modFile.Format()
+	formatted, err := modFile.Format()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to format %s: %w", goModFilePath, err)
+		return change, nil, fmt.Errorf("failed to format %s: %w", goModFilePath, err)
 	}
 
-	err = os.WriteFile(goModFilePath, data, 0666)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to write to %s: %w", goModFilePath, err)
+	change = utils.FileChange{
+		Path:   goModFilePath,
+		Before: data,
+		After:  formatted,
+	}
+	return change, pinned, nil
+}
+
+// resolveVersion keeps the pinned version of a mapped requirement in case it exists at the new module path,
+// because the history of the repositories was mirrored. Otherwise the fallback selects another version.
+func resolveVersion(ctx context.Context, repoDir string, dep module.Version, fallback string) error {
+	_, err := utils.GoModuleVersion(ctx, repoDir, dep.Path, dep.Version)
+	if err == nil {
+		fmt.Fprintf(utils.Stdout(ctx), "Dependency: keep %s\n", dep)
+		return nil
+	}
+	err = fmt.Errorf("%s does not exist at the new module path: %w", dep, err)
+
+	var query string
+	switch fallback {
+	case VersionFallbackLatestPatch:
+		var e error
+		query, e = patchQuery(dep.Version)
+		if e != nil {
+			return errors.Join(err, e)
+		}
+	case VersionFallbackLatest:
+		query = "latest"
+	default:
+		return err
+	}
+
+	version, e := utils.GoModuleVersion(ctx, repoDir, dep.Path, query)
+	if e != nil {
+		return errors.Join(err, fmt.Errorf("fallback %s failed: %w", fallback, e))
+	}
+
+	fmt.Fprintf(utils.Stdout(ctx), "Dependency: %s does not exist, fallback %s selects %s@%s\n", dep, fallback, dep.Path, version)
+	return utils.GoModRequire(ctx, repoDir, dep.Path, version)
+}
+
+// patchQuery returns the module query that selects the latest patch release of the pinned version.
+// Pseudo-versions do not belong to a release, so the query selects their commit, whose pseudo-version
+// differs in case the tags of the new repository differ. Incompatible versions keep their major version.
+func patchQuery(version string) (string, error) {
+	if module.IsPseudoVersion(version) {
+		return module.PseudoVersionRev(version)
+	}
+
+	// the build metadata of +incompatible versions is no part of the major and minor version
+	query := semver.MajorMinor(version)
+	if query == "" {
+		return "", fmt.Errorf("invalid version %s has no patch releases", version)
+	}
+	return query, nil
+}
+
+// migrateDirectives maps the module paths of replace and exclude directives.
+// Replacements with local directories keep their directory, retract directives only contain versions.
+func migrateDirectives(ctx context.Context, modFile *modfile.File, replacer *utils.Replacer) error {
+	for _, r := range modFile.Replace {
+		oldPath := replacer.Replace(r.Old.Path)
+		newPath := r.New.Path
+		if !modfile.IsDirectoryPath(newPath) {
+			newPath = replacer.Replace(newPath)
+		}
+
+		if oldPath == r.Old.Path && newPath == r.New.Path {
+			continue
+		}
+
+		fmt.Fprintf(utils.Stdout(ctx), "Replace: %s => %s -> %s => %s\n", r.Old, r.New, module.Version{Path: oldPath, Version: r.Old.Version}, module.Version{Path: newPath, Version: r.New.Version})
+
+		// the old and the new module path may be equal, e.g. for forks
+		arrow := slices.Index(r.Syntax.Token, "=>")
+		if arrow < 0 {
+			return fmt.Errorf("invalid replace directive: %s", strings.Join(r.Syntax.Token, " "))
+		}
+		renameToken(r.Syntax.Token[:arrow], r.Old.Path, oldPath)
+		renameToken(r.Syntax.Token[arrow+1:], r.New.Path, newPath)
+		r.Old.Path = oldPath
+		r.New.Path = newPath
+	}
+
+	for _, x := range modFile.Exclude {
+		path := replacer.Replace(x.Mod.Path)
+		if path == x.Mod.Path {
+			continue
+		}
+
+		fmt.Fprintf(utils.Stdout(ctx), "Exclude: %s -> %s@%s\n", x.Mod, path, x.Mod.Version)
+		renameToken(x.Syntax.Token, x.Mod.Path, path)
+		x.Mod.Path = path
+	}
+
+	if len(modFile.Retract) > 0 {
+		fmt.Fprintf(utils.Stdout(ctx), "Retract: keep %d retractions of the module versions\n", len(modFile.Retract))
+	}
+	return nil
+}
+
+// renameToken changes the module path token of a go.mod directive in place,
+// which keeps the block, the position and the comments of the directive, e.g. // indirect.
+func renameToken(tokens []string, oldPath, newPath string) {
+	quoted := modfile.AutoQuote(oldPath)
+	for idx, token := range tokens {
+		if token == oldPath || token == quoted {
+			tokens[idx] = modfile.AutoQuote(newPath)
+			return
+		}
+	}
+}
+
+// expectedModulePath returns the module path the module in the slash separated directory rel
+// of the repository should have after the migration. In remote mode the declared root of the
+// repository is swapped for the module path of the remote url, which keeps the declared subpath
+// and the major version suffix of the module.
+func expectedModulePath(ctx context.Context, declared, rel, root string, opts migrateOptions) (string, error) {
+	expected := declared
+	if opts.ModulePath == ModulePathRemote && opts.NewModule != "" {
+		// the entry was matched by the identity of the remote url, whose form may differ from the mapping
+		expected = swapRoot(declared, rel, root, opts.NewModule)
+	} else if opts.ModulePath == ModulePathRemote {
+		url, err := utils.GitRemoteUrl(ctx, opts.RepoDir, opts.RemoteName)
+		if err != nil {
+			return "", err
+		}
+
+		remoteRoot, err := opts.ModuleRules.ToModuleUrl(url)
+		if err != nil {
+			return "", err
+		}
+		expected = swapRoot(declared, rel, root, remoteRoot)
+	}
+
+	// also maps major version suffixes and nested modules
+	return utils.NewReplacer(opts.ModuleMap).Replace(expected), nil
+}
+
+// swapRoot replaces the root prefix of the declared module path with the new root.
+// Module paths outside of the root are placed in the directory rel of the new root
+// and keep their major version suffix.
+func swapRoot(declared, rel, root, newRoot string) string {
+	if root != "" {
+		if declared == root {
+			return newRoot
+		}
+		if subpath, found := strings.CutPrefix(declared, root+"/"); found {
+			return newRoot + "/" + subpath
+		}
 	}
 
-	return foundDependencies, additionalImports, nil
+	_, major, _ := module.SplitPathVersion(declared)
+	return path.Join(newRoot, rel) + major
 }
 
 func mergeMaps[K comparable, V any](ms ...map[K]V) map[K]V {
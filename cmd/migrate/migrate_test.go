@@ -0,0 +1,469 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jxsl13/module-migration/internal/gittest"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// newModuleProxy creates a file module proxy with the versions of the module and uses it for all go commands.
+// Pseudo-versions can also be queried by their revision.
+func newModuleProxy(t *testing.T, modulePath string, versions ...string) {
+	t.Helper()
+	proxyDir := t.TempDir()
+	versionDir := filepath.Join(proxyDir, modulePath, "@v")
+	require.NoError(t, os.MkdirAll(versionDir, 0777))
+
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(versionDir, name), []byte(content), 0666))
+	}
+
+	releases := make([]string, 0, len(versions))
+	for _, version := range versions {
+		info := fmt.Sprintf(`{"Version":%q,"Time":"2024-01-01T00:00:00Z"}`, version)
+		write(version+".info", info)
+		write(version+".mod", fmt.Sprintf("module %s\n", modulePath))
+		if !module.IsPseudoVersion(version) {
+			releases = append(releases, version)
+			continue
+		}
+		rev, err := module.PseudoVersionRev(version)
+		require.NoError(t, err)
+		write(rev+".info", info)
+	}
+	write("list", strings.Join(releases, "\n")+"\n")
+
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(proxyDir))
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOFLAGS", "-modcacherw")
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GOTOOLCHAIN", "local")
+}
+
+func TestResolveVersion(t *testing.T) {
+	const (
+		newPath = "example.com/new"
+		pseudo  = "v1.2.6-0.20240101000000-abcdef123456"
+	)
+	newModuleProxy(t, newPath, "v1.2.3", "v1.2.5", "v1.3.0", pseudo)
+
+	tests := []struct {
+		name     string
+		version  string
+		fallback string
+		want     string
+		wantErr  bool
+	}{
+		{name: "existing", version: "v1.2.3", fallback: VersionFallbackFail, want: "v1.2.3"},
+		{name: "latest patch", version: "v1.2.4", fallback: VersionFallbackLatestPatch, want: "v1.2.5"},
+		{name: "latest", version: "v1.2.4", fallback: VersionFallbackLatest, want: "v1.3.0"},
+		{name: "fail", version: "v1.2.4", fallback: VersionFallbackFail, wantErr: true},
+		{name: "no patch release", version: "v1.4.0", fallback: VersionFallbackLatestPatch, wantErr: true},
+		// the commit is found by its revision although the base version of the pseudo-version differs
+		{name: "pseudo-version", version: "v1.2.4-0.20240101000000-abcdef123456", fallback: VersionFallbackLatestPatch, want: pseudo},
+		{name: "unknown pseudo-version", version: "v1.2.4-0.20240101000000-123456abcdef", fallback: VersionFallbackLatestPatch, wantErr: true},
+		{name: "pseudo-version latest", version: "v1.2.4-0.20240101000000-123456abcdef", fallback: VersionFallbackLatest, want: "v1.3.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoDir := t.TempDir()
+			goMod := filepath.Join(repoDir, "go.mod")
+			content := fmt.Sprintf("module example.com/app\n\ngo 1.21\n\nrequire %s %s // indirect\n", newPath, tt.version)
+			require.NoError(t, os.WriteFile(goMod, []byte(content), 0666))
+
+			err := resolveVersion(context.Background(), repoDir, module.Version{Path: newPath, Version: tt.version}, tt.fallback)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(goMod)
+			require.NoError(t, err)
+			require.Contains(t, string(data), fmt.Sprintf("require %s %s // indirect\n", newPath, tt.want))
+		})
+	}
+}
+
+func TestPatchQuery(t *testing.T) {
+	for version, want := range map[string]string{
+		"v1.2.3":                               "v1.2",
+		"v2.3.4+incompatible":                  "v2.3",
+		"v0.0.0-20240101000000-abcdef123456":   "abcdef123456",
+		"v1.2.4-0.20240101000000-abcdef123456": "abcdef123456",
+	} {
+		query, err := patchQuery(version)
+		require.NoError(t, err, version)
+		require.Equal(t, want, query, version)
+	}
+
+	_, err := patchQuery("latest")
+	require.Error(t, err)
+}
+
+func TestMigrateDirectives(t *testing.T) {
+	replacer := utils.NewReplacer(map[string]string{
+		"old.example.com/lib":  "new.example.com/lib",
+		"old.example.com/fork": "new.example.com/fork",
+	})
+
+	tests := []struct {
+		name   string
+		goMod  string
+		expect string
+	}{
+		{
+			name:   "unversioned",
+			goMod:  "replace old.example.com/lib => old.example.com/fork v1.0.0\n",
+			expect: "replace new.example.com/lib => new.example.com/fork v1.0.0\n",
+		},
+		{
+			name:   "versioned",
+			goMod:  "replace old.example.com/lib v1.2.3 => old.example.com/lib v1.2.4\n",
+			expect: "replace new.example.com/lib v1.2.3 => new.example.com/lib v1.2.4\n",
+		},
+		{
+			name:   "major version suffix",
+			goMod:  "replace old.example.com/lib/v2 v2.0.0 => old.example.com/lib/v2 v2.0.1\n",
+			expect: "replace new.example.com/lib/v2 v2.0.0 => new.example.com/lib/v2 v2.0.1\n",
+		},
+		{
+			name:   "local directory",
+			goMod:  "replace old.example.com/lib => ../lib\n",
+			expect: "replace new.example.com/lib => ../lib\n",
+		},
+		{
+			name:   "local directory that looks like a module path",
+			goMod:  "replace old.example.com/lib v1.2.3 => ./old.example.com/lib\n",
+			expect: "replace new.example.com/lib v1.2.3 => ./old.example.com/lib\n",
+		},
+		{
+			name:   "fork of a mapped module",
+			goMod:  "replace old.example.com/lib => github.com/someone/lib v1.0.0\n",
+			expect: "replace new.example.com/lib => github.com/someone/lib v1.0.0\n",
+		},
+		{
+			name:   "mapped fork of an unmapped module",
+			goMod:  "replace github.com/someone/lib => old.example.com/fork v1.0.0\n",
+			expect: "replace github.com/someone/lib => new.example.com/fork v1.0.0\n",
+		},
+		{
+			name:   "unmapped",
+			goMod:  "replace github.com/someone/lib => github.com/other/lib v1.0.0\n",
+			expect: "replace github.com/someone/lib => github.com/other/lib v1.0.0\n",
+		},
+		{
+			name: "block with comments",
+			goMod: "replace (\n" +
+				"\t// keep the fix\n" +
+				"\told.example.com/lib => old.example.com/fork v1.0.0 // fix\n" +
+				"\tgithub.com/someone/lib => ../lib\n" +
+				")\n",
+			expect: "replace (\n" +
+				"\t// keep the fix\n" +
+				"\tnew.example.com/lib => new.example.com/fork v1.0.0 // fix\n" +
+				"\tgithub.com/someone/lib => ../lib\n" +
+				")\n",
+		},
+		{
+			name:   "exclude",
+			goMod:  "exclude (\n\told.example.com/lib v1.2.3\n\tgithub.com/someone/lib v1.0.0\n)\n",
+			expect: "exclude (\n\tnew.example.com/lib v1.2.3\n\tgithub.com/someone/lib v1.0.0\n)\n",
+		},
+		{
+			name:   "retract",
+			goMod:  "retract v1.0.0 // broken\n",
+			expect: "retract v1.0.0 // broken\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const header = "module example.com/app\n\ngo 1.21\n\n"
+			modFile, err := modfile.Parse("go.mod", []byte(header+tt.goMod), nil)
+			require.NoError(t, err)
+
+			err = migrateDirectives(context.Background(), modFile, replacer)
+			require.NoError(t, err)
+
+			data, err := modFile.Format()
+			require.NoError(t, err)
+			require.Equal(t, header+tt.expect, string(data))
+		})
+	}
+}
+
+func TestMigrateVanityImport(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0666))
+		return path
+	}
+
+	csvPath := write("mapping.csv", "old;new\nssh://git@git.company.com/project/*;git@github.com:company/go-*\n")
+	goMod := write("go.mod", "module example.com/app\n\ngo 1.21\n\nrequire go.company.com/lib v1.2.3\n")
+	mainGo := write("main.go", "package main\n\nimport _ \"go.company.com/lib/pkg\"\n\nfunc main() {}\n")
+
+	vanity, err := mapping.ParseVanityTable([]string{"go.company.com=git.company.com/project"})
+	require.NoError(t, err)
+
+	m, err := mapping.Load(csvPath, 0, 1, ';', mapping.WithVanity(vanity))
+	require.NoError(t, err)
+	resolved, err := m.Expand(ctx, nil, []string{"go.company.com/lib"})
+	require.NoError(t, err)
+
+	c := migrateContext{Config: &MigrateConfig{ModulePath: ModulePathRemote, vanity: vanity}}
+	moduleMap := c.moduleMap(resolved)
+
+	change, pinned, err := migrateGoMod(ctx, goMod, "example.com/app", moduleMap)
+	require.NoError(t, err)
+	require.Contains(t, string(change.After), "require github.com/company/go-lib v1.2.3\n")
+	require.Equal(t, []module.Version{{Path: "github.com/company/go-lib", Version: "v1.2.3"}}, pinned)
+
+	replaced, _, err := utils.ComputeReplaceInDir(dir, nil, []*regexp.Regexp{regexp.MustCompile(`\.go$`)}, utils.NewReplacer(moduleMap))
+	require.NoError(t, err)
+	require.Len(t, replaced, 1)
+	require.Equal(t, mainGo, replaced[0].Path)
+	require.Contains(t, string(replaced[0].After), `import _ "github.com/company/go-lib/pkg"`)
+
+	// declared vanity import paths are kept unless a new module path is requested explicitly
+	c.Config.ModulePath = ModulePathDeclared
+	change, pinned, err = migrateGoMod(ctx, goMod, "example.com/app", c.moduleMap(resolved))
+	require.NoError(t, err)
+	require.False(t, change.Changed())
+	require.Empty(t, pinned)
+}
+
+func TestFindModulesMajorVersion(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		goMods map[string]string
+		expect map[string]string
+	}{
+		{
+			name:   "root",
+			goMods: map[string]string{".": "git.company.com/project/lib/v2"},
+			expect: map[string]string{".": "github.com/company/lib/v2"},
+		},
+		{
+			name: "nested",
+			goMods: map[string]string{
+				".":   "git.company.com/project/lib",
+				"api": "git.company.com/project/lib/api/v2",
+			},
+			expect: map[string]string{
+				".":   "github.com/company/lib",
+				"api": "github.com/company/lib/api/v2",
+			},
+		},
+		{
+			name:   "nested without root module",
+			goMods: map[string]string{"api": "git.company.com/project/lib/api/v3"},
+			expect: map[string]string{"api": "github.com/company/lib/api/v3"},
+		},
+		{
+			name: "declared root differs from the remote",
+			goMods: map[string]string{
+				".":     "go.company.com/lib/v2",
+				"tools": "go.company.com/lib/v2/cmd/tools",
+			},
+			expect: map[string]string{
+				".":     "github.com/company/lib/v2",
+				"tools": "github.com/company/lib/v2/cmd/tools",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoDir := t.TempDir()
+			gittest.Git(t, repoDir, "init")
+			gittest.Git(t, repoDir, "remote", "add", "origin", "ssh://git@git.company.com/project/lib.git")
+			for rel, modulePath := range tt.goMods {
+				dir := filepath.Join(repoDir, filepath.FromSlash(rel))
+				require.NoError(t, os.MkdirAll(dir, 0777))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module "+modulePath+"\n"), 0666))
+			}
+
+			opts := migrateOptions{
+				RepoDir:    repoDir,
+				RemoteName: "origin",
+				ModulePath: ModulePathRemote,
+				ModuleMap:  map[string]string{"git.company.com/project/lib": "github.com/company/lib"},
+			}
+			modules, err := findModules(ctx, opts)
+			require.NoError(t, err)
+
+			actual := make(map[string]string, len(modules))
+			for _, m := range modules {
+				actual[m.Rel] = m.NewPath
+			}
+			require.Equal(t, tt.expect, actual)
+		})
+	}
+}
+
+func TestExpectedModulePathUrlForms(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "mapping.csv")
+	mappingCSV := "old;new\nssh://git@git.company.com:7999/project/repo.git;git@github.com:company/go-repo.git\n"
+	require.NoError(t, os.WriteFile(csvPath, []byte(mappingCSV), 0666))
+
+	// the clone uses the http url of the repository
+	repoDir := filepath.Join(dir, "repo")
+	gittest.Git(t, "", "init", repoDir)
+	gittest.Git(t, repoDir, "remote", "add", "origin", "https://git.company.com/scm/project/repo.git")
+
+	cfg := newMigrateConfig()
+	cfg.CSVPath = csvPath
+	require.NoError(t, cfg.Validate())
+	c := migrateContext{Ctx: ctx, Config: cfg}
+
+	m, err := mapping.Load(csvPath, 0, 1, ';')
+	require.NoError(t, err)
+	resolved, err := m.ExpandRepos(ctx, []string{repoDir}, cfg.RemoteName)
+	require.NoError(t, err)
+
+	opts, err := c.migrateOptions(ctx, repoDir, resolved, c.moduleMap(resolved))
+	require.NoError(t, err)
+
+	expected, err := expectedModulePath(ctx, "git.company.com/project/repo", ".", "git.company.com/project/repo", opts)
+	require.NoError(t, err)
+	require.Equal(t, "github.com/company/go-repo", expected)
+
+	expected, err = expectedModulePath(ctx, "git.company.com/project/repo/tools", "tools", "git.company.com/project/repo", opts)
+	require.NoError(t, err)
+	require.Equal(t, "github.com/company/go-repo/tools", expected)
+
+	// the declared major version suffix is kept
+	expected, err = expectedModulePath(ctx, "git.company.com/project/repo/v2", ".", "git.company.com/project/repo", opts)
+	require.NoError(t, err)
+	require.Equal(t, "github.com/company/go-repo/v2", expected)
+
+	expected, err = expectedModulePath(ctx, "git.company.com/project/repo/api/v2", "api", "git.company.com/project/repo", opts)
+	require.NoError(t, err)
+	require.Equal(t, "github.com/company/go-repo/api/v2", expected)
+}
+
+func TestMigrateResumeRollback(t *testing.T) {
+	gittest.SetIdentity(t)
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOTOOLCHAIN", "local")
+
+	oldDir := gittest.NewRemote(t)
+	// the build of the migrated module fails
+	gittest.Commit(t, oldDir, "main", map[string]string{
+		".gitignore": "NOTICE.md\n",
+		"go.mod":     "module example.com/app\n\ngo 1.21\n",
+		"main.go":    "package main\n\nfunc main() { undefined() }\n",
+	})
+	newDir := gittest.NewRemote(t)
+
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "mapping.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("old;new\n"+oldDir+";"+newDir+"\n"), 0666))
+	noticePath := filepath.Join(dir, "NOTICE.md")
+	require.NoError(t, os.WriteFile(noticePath, []byte("migrated\n"), 0666))
+
+	rootPath := filepath.Join(dir, "root")
+	repoDir := filepath.Join(rootPath, "app")
+	gittest.Git(t, "", "clone", oldDir, repoDir)
+	head := gittest.Git(t, repoDir, "rev-parse", "HEAD")
+	// the ignored file is overwritten by the migration
+	localNotice := filepath.Join(repoDir, "NOTICE.md")
+	require.NoError(t, os.WriteFile(localNotice, []byte("local\n"), 0666))
+
+	migrate := func(keepFailed bool) {
+		cfg := newMigrateConfig()
+		cfg.CSVPath = csvPath
+		cfg.ModulePath = ModulePathDeclared
+		cfg.AdditionalFiles = noticePath
+		cfg.KeepFailed = keepFailed
+		require.NoError(t, cfg.Validate())
+
+		c := migrateContext{Ctx: context.Background(), Config: cfg, RootPath: rootPath}
+		err := c.RunE(&cobra.Command{Use: "migrate"}, nil)
+		require.Error(t, err)
+	}
+	read := func() string {
+		data, err := os.ReadFile(localNotice)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// the first run fails and keeps the half migrated repository on the migration branch
+	migrate(true)
+	require.Equal(t, "chore/module-migration", gittest.Git(t, repoDir, "branch", "--show-current"))
+	require.Equal(t, "migrated\n", read())
+
+	// the resumed run fails again and restores the repository as it was before the first run
+	migrate(false)
+	require.Equal(t, "main", gittest.Git(t, repoDir, "branch", "--show-current"))
+	require.Equal(t, head, gittest.Git(t, repoDir, "rev-parse", "HEAD"))
+	require.Empty(t, gittest.Git(t, repoDir, "status", "--porcelain"))
+	require.False(t, utils.GitExistsBranch(context.Background(), repoDir, "refs/heads/chore/module-migration"))
+	require.Equal(t, "local\n", read())
+}
+
+func TestMigrateKeepsUnpushedCommits(t *testing.T) {
+	gittest.SetIdentity(t)
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOTOOLCHAIN", "local")
+
+	oldDir := gittest.NewRemote(t)
+	gittest.Commit(t, oldDir, "main", map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+	newDir := gittest.NewRemote(t)
+
+	for _, worktree := range []bool{false, true} {
+		t.Run(fmt.Sprintf("worktree=%t", worktree), func(t *testing.T) {
+			dir := t.TempDir()
+			csvPath := filepath.Join(dir, "mapping.csv")
+			require.NoError(t, os.WriteFile(csvPath, []byte("old;new\n"+oldDir+";"+newDir+"\n"), 0666))
+
+			rootPath := filepath.Join(dir, "root")
+			repoDir := filepath.Join(rootPath, "app")
+			gittest.Git(t, "", "clone", oldDir, repoDir)
+
+			// the migration branch has a commit that only exists locally
+			gittest.Git(t, repoDir, "checkout", "-b", "chore/module-migration")
+			require.NoError(t, os.WriteFile(filepath.Join(repoDir, "local.txt"), []byte("local\n"), 0666))
+			gittest.Git(t, repoDir, "add", "--all")
+			gittest.Git(t, repoDir, "commit", "-m", "local")
+			local := gittest.Git(t, repoDir, "rev-parse", "HEAD")
+			gittest.Git(t, repoDir, "checkout", "main")
+
+			cfg := newMigrateConfig()
+			cfg.CSVPath = csvPath
+			cfg.ModulePath = ModulePathDeclared
+			cfg.Worktree = worktree
+			require.NoError(t, cfg.Validate())
+
+			c := migrateContext{Ctx: context.Background(), Config: cfg, RootPath: rootPath}
+			require.Error(t, c.RunE(&cobra.Command{Use: "migrate"}, nil))
+
+			require.Equal(t, "main", gittest.Git(t, repoDir, "branch", "--show-current"))
+			require.Equal(t, local, gittest.Git(t, repoDir, "rev-parse", "refs/heads/chore/module-migration"))
+		})
+	}
+}
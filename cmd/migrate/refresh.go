@@ -0,0 +1,155 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/spf13/cobra"
+)
+
+func NewRefreshCmd() *cobra.Command {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+
+	refreshContext := refreshContext{
+		migrateContext{
+			Ctx:    ctx,
+			repeat: true,
+		},
+	}
+
+	// cmd represents the run command
+	cmd := &cobra.Command{
+		Use:   "refresh",
+		Short: "re-runs the migration of all repositories with an existing migration branch on top of the latest default branch, force pushes the branch and updates the pull request",
+		Args:  cobra.ExactArgs(1),
+		RunE:  refreshContext.RunE,
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+
+			cancel()
+			return nil
+		},
+	}
+
+	// register flags but defer parsing and validation of the final values
+	cmd.PreRunE = refreshContext.PreRunE(cmd)
+
+	return cmd
+}
+
+// refreshContext shares the configuration of the migrate subcommand
+type refreshContext struct {
+	migrateContext
+}
+
+func (c *refreshContext) RunE(cmd *cobra.Command, args []string) (err error) {
+	if c.Config.BranchName == "" {
+		return errors.New("branch name is empty, refresh requires a migration branch")
+	}
+	if c.Config.DryRun {
+		return errors.New("refresh does not support --dry-run, use migrate --dry-run instead")
+	}
+	return c.run(cmd, "refresh", "refreshed", refreshRepo)
+}
+
+// refreshRepo migrates the current default branch again instead of rebasing the existing migration branch,
+// because rebasing conflicts on every changed import block.
+func refreshRepo(ctx context.Context, opts migrateOptions) error {
+	var (
+		repoDir      = opts.RepoDir
+		remoteName   = opts.RemoteName
+		targetBranch = opts.TargetBranch
+		remoteBranch = fmt.Sprintf("refs/remotes/%s/%s", remoteName, targetBranch)
+	)
+
+	// the lease protects commits that were pushed to the migration branch by someone else in the meantime
+	var lease string
+	err := fleet.Measure(ctx, "find branch", func() (err error) {
+		err = utils.GitFetch(ctx, repoDir, remoteName)
+		if err != nil {
+			return err
+		}
+		if !utils.GitExistsBranch(ctx, repoDir, remoteBranch) {
+			return fmt.Errorf("%w: no migration branch %s/%s", fleet.ErrNothingToDo, remoteName, targetBranch)
+		}
+		lease, err = utils.GitRevParse(ctx, repoDir, remoteBranch)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = migrateRepo(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	workDir := repoDir
+	if opts.Worktree {
+		workDir, err = utils.GitWorktreeDir(ctx, repoDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = commitMigration(ctx, workDir)
+	if err != nil {
+		return err
+	}
+
+	err = fleet.RunStep(ctx, "push", func() error {
+		return utils.GitForcePushWithLease(ctx, workDir, remoteName, targetBranch, lease)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = fleet.RunStep(ctx, "pr", func() error {
+		return utils.UpdateGithubPullRequest(ctx, workDir, targetBranch, utils.PullRequest{
+			Title:     "chore: Go module migration",
+			Base:      opts.DefaultBranch,
+			Reviewers: opts.Reviewers,
+			Labels:    opts.Labels,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.Worktree {
+		return fleet.RunStep(ctx, "remove worktree", func() error {
+			return utils.GitWorktreeRemove(ctx, repoDir, workDir)
+		})
+	}
+
+	found, err := utils.HasStashedLocalChanges(ctx, repoDir)
+	if err != nil || !found {
+		return err
+	}
+	return fleet.RunStep(ctx, "restore local changes", func() error {
+		return utils.RestoreLocalChanges(ctx, repoDir)
+	})
+}
+
+// commitMigration commits all changes of the working tree, which must only contain changes of the migration.
+func commitMigration(ctx context.Context, workDir string) error {
+	return fleet.RunStep(ctx, "commit", func() error {
+		changed, err := utils.GitChangedFiles(ctx, workDir)
+		if err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			return fmt.Errorf("%w: the default branch was already migrated", fleet.ErrNothingToDo)
+		}
+
+		err = utils.GitAddAll(ctx, workDir)
+		if err != nil {
+			return err
+		}
+		return utils.GitCommit(ctx, workDir, "chore: Go module migration")
+	})
+}
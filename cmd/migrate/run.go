@@ -0,0 +1,265 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/jxsl13/module-migration/config"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/spf13/cobra"
+)
+
+// cloneDepth is the depth of the shallow clones, the complete history is only fetched
+// in case the new remote repository does not contain the history yet.
+const cloneDepth = 1
+
+func NewRunCmd() *cobra.Command {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+
+	runContext := runContext{
+		migrateContext{
+			Ctx: ctx,
+		},
+	}
+
+	// cmd represents the run command
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "clones every old repository of the mapping file into a temporary workspace, migrates, commits and pushes it to the new remote url, creates a pull request and removes the workspace",
+		Args:  cobra.NoArgs,
+		RunE:  runContext.RunE,
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+
+			cancel()
+			return nil
+		},
+	}
+
+	// register flags but defer parsing and validation of the final values
+	cmd.PreRunE = runContext.PreRunE(cmd)
+
+	return cmd
+}
+
+// runContext shares the configuration of the migrate subcommand.
+// The root path is the temporary workspace.
+type runContext struct {
+	migrateContext
+}
+
+func (c *runContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
+	c.Config = newMigrateConfig()
+
+	runParser := config.RegisterFlags(c.Config, true, cmd)
+
+	return func(cmd *cobra.Command, args []string) error {
+		return runParser()
+	}
+}
+
+func (c *runContext) RunE(cmd *cobra.Command, args []string) (err error) {
+	if c.Config.BranchName == "" {
+		return errors.New("branch name is empty, run requires a migration branch")
+	}
+	if c.Config.Worktree {
+		return errors.New("run does not support --worktree, every repository is migrated in a temporary clone")
+	}
+
+	started := time.Now()
+	m, err := mapping.Load(
+		c.Config.CSVPath,
+		c.Config.OldColumnIndex(),
+		c.Config.NewColumnIndex(),
+		c.Config.CommaRune(),
+		mapping.WithResolveChains(c.Config.ResolveChains),
+		mapping.WithOutput(utils.Stdout(c.Ctx)),
+		mapping.WithModulePaths(c.Config.ModulePathRules()),
+		mapping.WithVanity(c.Config.VanityTable()),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(m.Rules) > 0 {
+		fmt.Fprintf(utils.Stdout(c.Ctx), "Rules: %d wildcard rules are only applied to dependencies, only repositories of explicit rows are cloned\n", len(m.Rules))
+	}
+
+	workspace, err := os.MkdirTemp("", "module-migration-")
+	if err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	c.RootPath = workspace
+
+	// the repositories are cloned into <workspace>/<host>/<path>
+	var (
+		repoDirs   = make([]string, 0, len(m.Entries))
+		entries    = make(map[string]mapping.Entry, len(m.Entries))
+		remoteUrls = make(map[string]string, len(m.Entries))
+	)
+	for _, e := range m.Entries {
+		rel, err := utils.ToRepoPath(e.OldUrl)
+		if err != nil {
+			return err
+		}
+
+		repoDir := filepath.Join(workspace, filepath.FromSlash(rel))
+		if _, found := entries[repoDir]; found {
+			// duplicates are reported by mapping validate
+			continue
+		}
+		repoDirs = append(repoDirs, repoDir)
+		entries[repoDir] = e
+		remoteUrls[repoDir] = e.OldUrl
+	}
+
+	fleetOptions := c.Config.FleetOptions()
+	fleetOptions.RemoteUrls = remoteUrls
+
+	var kept atomic.Bool
+	executor := fleet.NewExecutor(fleetOptions)
+	results := executor.Run(c.Ctx, repoDirs, func(ctx context.Context, repoDir string) error {
+		var status runStatus
+		err := c.runRepo(ctx, m, entries[repoDir], repoDir, &status)
+		c.printStatus(ctx, status.verb, status.past, repoDir, err)
+
+		if err != nil && !fleet.IsSkipped(err) && c.Config.KeepFailed {
+			kept.Store(true)
+			fmt.Fprintf(utils.Stdout(ctx), "Keep failed: the clone is kept in %s\n", repoDir)
+			return err
+		}
+
+		e := os.RemoveAll(repoDir)
+		if e != nil {
+			return errors.Join(err, fmt.Errorf("failed to remove clone: %w", e))
+		}
+		return err
+	})
+
+	err = results.PrintSummary(os.Stdout, c.RootPath)
+	if err != nil {
+		return err
+	}
+
+	if c.Config.Report != "" {
+		err = report.New(cmd.Name(), c.RootPath, started, results).WriteFile(c.Config.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !kept.Load() {
+		err = os.RemoveAll(workspace)
+		if err != nil {
+			return fmt.Errorf("failed to remove workspace: %w", err)
+		}
+	}
+
+	err = results.Err()
+	if err != nil {
+		// the errors of the repositories were already printed
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return err
+	}
+	return nil
+}
+
+// runStatus is the verb and its past tense of the last step of runRepo that was started.
+type runStatus struct {
+	verb string
+	past string
+}
+
+// runRepo clones, migrates, commits and pushes a single repository of the mapping.
+// The status is updated before every step, so it either names the failed or the last completed step.
+func (c *runContext) runRepo(ctx context.Context, m *mapping.Mapping, entry mapping.Entry, repoDir string, status *runStatus) error {
+	*status = runStatus{"clone", "cloned"}
+	if entry.Skip {
+		return fleet.ErrSkipped
+	}
+
+	remoteName := c.Config.RemoteName
+	err := fleet.RunStep(ctx, "clone", func() error {
+		return utils.GitClone(ctx, entry.OldUrl, repoDir, remoteName, entry.DefaultBranch, cloneDepth)
+	})
+	if err != nil {
+		return err
+	}
+
+	moduleDirs, err := utils.FindGoModDirs(repoDir)
+	if err != nil {
+		return err
+	}
+	if len(moduleDirs) == 0 {
+		return fmt.Errorf("%w: %s has no go.mod", fleet.ErrNothingToDo, entry.OldUrl)
+	}
+
+	// rules are expanded against the required modules of the clone
+	resolved, err := m.ExpandRepos(ctx, []string{repoDir}, remoteName)
+	if err != nil {
+		return err
+	}
+
+	opts, err := c.migrateOptions(ctx, repoDir, resolved, c.moduleMap(resolved))
+	if err != nil {
+		return err
+	}
+
+	*status = runStatus{"migrate", "migrated"}
+	err = migrateRepo(ctx, opts)
+	if err != nil || opts.DryRun {
+		return err
+	}
+
+	// the clone is removed afterwards, there is no need to keep the old url
+	*status = runStatus{"commit", "committed"}
+	err = fleet.RunStep(ctx, "remote", func() error {
+		return utils.GitSwitchRemote(ctx, repoDir, remoteName, "", entry.NewUrl)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = commitMigration(ctx, repoDir)
+	if err != nil {
+		return err
+	}
+
+	*status = runStatus{"push", "pushed"}
+	err = fleet.RunStep(ctx, "push", func() error {
+		err := utils.GitPushUpstream(ctx, repoDir, remoteName, opts.TargetBranch)
+		if !errors.Is(err, utils.ErrShallowUpdate) {
+			return err
+		}
+
+		// the new remote repository does not contain the history of the shallow clone yet
+		fmt.Fprintf(utils.Stdout(ctx), "Push: fetching the complete history of %s\n", entry.OldUrl)
+		err = utils.GitFetchUnshallow(ctx, repoDir, entry.OldUrl)
+		if err != nil {
+			return err
+		}
+		return utils.GitPushUpstream(ctx, repoDir, remoteName, opts.TargetBranch)
+	})
+	if err != nil {
+		return err
+	}
+
+	*status = runStatus{"create the pull request of", "created the pull request of"}
+	return fleet.RunStep(ctx, "pr", func() error {
+		return utils.CreateGithubPullRequest(ctx, repoDir, utils.PullRequest{
+			Title:     "chore: Go module migration",
+			Base:      opts.DefaultBranch,
+			Reviewers: opts.Reviewers,
+			Labels:    opts.Labels,
+		})
+	})
+}
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jxsl13/module-migration/internal/gittest"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	gittest.SetIdentity(t)
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOTOOLCHAIN", "local")
+
+	oldDir := gittest.NewRemote(t)
+	gittest.Commit(t, oldDir, "main", map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+	gittest.Commit(t, oldDir, "main", map[string]string{"README.md": "app\n"})
+	newDir := gittest.NewRemote(t)
+
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "mapping.csv")
+	// file urls, because local paths are never cloned shallow
+	mappingCSV := "old;new\n" + gittest.URL(oldDir) + ";" + gittest.URL(newDir) + "\n"
+	require.NoError(t, os.WriteFile(csvPath, []byte(mappingCSV), 0666))
+	noticePath := filepath.Join(dir, "NOTICE.md")
+	require.NoError(t, os.WriteFile(noticePath, []byte("migrated\n"), 0666))
+
+	cfg := newMigrateConfig()
+	cfg.CSVPath = csvPath
+	cfg.BranchName = "chore/module-migration"
+	cfg.ModulePath = ModulePathDeclared
+	cfg.AdditionalFiles = noticePath
+	require.NoError(t, cfg.Validate())
+
+	c := runContext{migrateContext{Ctx: context.Background(), Config: cfg}}
+	err := c.RunE(&cobra.Command{Use: "run"}, nil)
+	require.NoError(t, err)
+
+	// the push of the shallow clone fails, so the complete history is fetched and pushed
+	branch := "refs/heads/" + cfg.BranchName
+	require.Equal(t, "3", gittest.Git(t, newDir, "rev-list", "--count", branch))
+	require.Equal(t, gittest.Git(t, oldDir, "rev-parse", "main"), gittest.Git(t, newDir, "rev-parse", branch+"~1"))
+	require.Equal(t, "migrated", gittest.Git(t, newDir, "show", branch+":NOTICE.md"))
+
+	// the workspace is removed
+	_, err = os.Stat(c.RootPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,222 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jxsl13/module-migration/config"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/spf13/cobra"
+)
+
+func NewMirrorCmd() *cobra.Command {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+
+	mirrorContext := mirrorContext{
+		Ctx: ctx,
+	}
+
+	// cmd represents the run command
+	cmd := &cobra.Command{
+		Use:   "mirror",
+		Short: "pushes all branches, tags and notes of every old repository of the mapping file to the new repository and verifies that all refs match afterwards",
+		Args:  cobra.NoArgs,
+		RunE:  mirrorContext.RunE,
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+
+			cancel()
+			return nil
+		},
+	}
+
+	// register flags but defer parsing and validation of the final values
+	cmd.PreRunE = mirrorContext.PreRunE(cmd)
+
+	return cmd
+}
+
+// mirrorContext has no root path, the mirrors are created in a temporary workspace
+type mirrorContext struct {
+	Ctx    context.Context
+	Config *MirrorConfig
+}
+
+func (c *mirrorContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
+	c.Config = &MirrorConfig{
+		ResolveChains: true,
+		Jobs:          "0",
+		KeepGoing:     true,
+		CSVPath:       "./mapping.csv",
+		Comma:         ";", // default separator
+		OldColumn:     "0",
+		NewColumn:     "1",
+	}
+
+	runParser := config.RegisterFlags(c.Config, true, cmd)
+
+	return func(cmd *cobra.Command, args []string) error {
+		return runParser()
+	}
+}
+
+func (c *mirrorContext) RunE(cmd *cobra.Command, args []string) (err error) {
+	started := time.Now()
+	m, err := mapping.Load(
+		c.Config.CSVPath,
+		c.Config.OldColumnIndex(),
+		c.Config.NewColumnIndex(),
+		c.Config.CommaRune(),
+		mapping.WithResolveChains(c.Config.ResolveChains),
+		mapping.WithOutput(utils.Stdout(c.Ctx)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(m.Rules) > 0 {
+		fmt.Fprintf(utils.Stdout(c.Ctx), "Rules: %d wildcard rules are ignored, only repositories of explicit rows are mirrored\n", len(m.Rules))
+	}
+
+	workspace, err := os.MkdirTemp("", "module-migration-mirror-")
+	if err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	defer os.RemoveAll(workspace)
+
+	var (
+		repoDirs   = make([]string, 0, len(m.Entries))
+		entries    = make(map[string]mapping.Entry, len(m.Entries))
+		remoteUrls = make(map[string]string, len(m.Entries))
+	)
+	for _, e := range m.Entries {
+		rel, err := utils.ToRepoPath(e.OldUrl)
+		if err != nil {
+			return err
+		}
+
+		repoDir := filepath.Join(workspace, filepath.FromSlash(rel)+".git")
+		if _, found := entries[repoDir]; found {
+			// duplicates are reported by mapping validate
+			continue
+		}
+		repoDirs = append(repoDirs, repoDir)
+		entries[repoDir] = e
+		remoteUrls[repoDir] = e.OldUrl
+	}
+
+	fleetOptions := c.Config.FleetOptions()
+	fleetOptions.RemoteUrls = remoteUrls
+
+	executor := fleet.NewExecutor(fleetOptions)
+	results := executor.Run(c.Ctx, repoDirs, func(ctx context.Context, repoDir string) error {
+		entry := entries[repoDir]
+		err := mirror(ctx, entry, repoDir, c.Config.LFS)
+		if fleet.IsSkipped(err) {
+			fmt.Fprintf(utils.Stdout(ctx), "Skipped %s: %v\n", entry.OldUrl, err)
+		} else if err != nil {
+			fmt.Fprintf(utils.Stderr(ctx), "Error: failed to mirror %s to %s: %v\n", entry.OldUrl, entry.NewUrl, err)
+		} else {
+			fmt.Fprintf(utils.Stdout(ctx), "Successfully mirrored %s to %s\n", entry.OldUrl, entry.NewUrl)
+		}
+
+		// the mirrors may be large
+		e := os.RemoveAll(repoDir)
+		if e != nil {
+			return errors.Join(err, fmt.Errorf("failed to remove mirror: %w", e))
+		}
+		return err
+	})
+
+	err = results.PrintSummary(os.Stdout, workspace)
+	if err != nil {
+		return err
+	}
+
+	if c.Config.Report != "" {
+		err = report.New(cmd.Name(), workspace, started, results).WriteFile(c.Config.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = results.Err()
+	if err != nil {
+		// the errors of the repositories were already printed
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return err
+	}
+	return nil
+}
+
+// mirror pushes all branches, tags and notes of the old url to the new url.
+// Refs that diverged in the new repository are never overwritten but reported as mismatch.
+func mirror(ctx context.Context, entry mapping.Entry, repoDir string, lfs bool) error {
+	if entry.Skip {
+		return fleet.ErrSkipped
+	}
+
+	err := fleet.RunStep(ctx, "clone", func() error {
+		return utils.GitCloneMirror(ctx, entry.OldUrl, repoDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	if lfs {
+		err = fleet.RunStep(ctx, "lfs fetch", func() error {
+			return utils.GitLfsFetchAll(ctx, repoDir, entry.OldUrl)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// the refs are pushed before the verification in order to report all mismatches
+	pushErr := fleet.RunStep(ctx, "push", func() error {
+		return utils.GitPushRefs(ctx, repoDir, entry.NewUrl, utils.MirrorRefspecs...)
+	})
+
+	if lfs && pushErr == nil {
+		err = fleet.RunStep(ctx, "lfs push", func() error {
+			return utils.GitLfsPushAll(ctx, repoDir, entry.NewUrl)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = fleet.RunStep(ctx, "verify", func() error {
+		source, err := utils.GitLsRemote(ctx, repoDir, entry.OldUrl)
+		if err != nil {
+			return err
+		}
+		target, err := utils.GitLsRemote(ctx, repoDir, entry.NewUrl)
+		if err != nil {
+			return err
+		}
+
+		mismatches := utils.CompareRefs(source, target)
+		if len(mismatches) == 0 {
+			fmt.Fprintf(utils.Stdout(ctx), "Verify: all %d refs of %s match\n", len(source), entry.OldUrl)
+			return nil
+		}
+
+		lines := make([]string, 0, len(mismatches))
+		for _, mm := range mismatches {
+			fmt.Fprintf(utils.Stdout(ctx), "Mismatch: %s\n", mm)
+			lines = append(lines, mm.String())
+		}
+		return fmt.Errorf("%d of %d refs do not match: %s", len(mismatches), len(source), strings.Join(lines, ", "))
+	})
+	return errors.Join(pushErr, err)
+}
@@ -0,0 +1,35 @@
+package mirror
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jxsl13/module-migration/internal/gittest"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	ctx := context.Background()
+	oldDir := gittest.NewRemote(t)
+	gittest.Commit(t, oldDir, "main", map[string]string{"go.mod": "module example.com/app\n"})
+	gittest.Commit(t, oldDir, "feature", map[string]string{"feature.go": "package app\n"})
+	gittest.Git(t, oldDir, "tag", "v1.0.0", "main")
+	newDir := gittest.NewRemote(t)
+
+	entry, err := mapping.NewEntry(oldDir, newDir)
+	require.NoError(t, err)
+
+	err = mirror(ctx, entry, filepath.Join(t.TempDir(), "app.git"), false)
+	require.NoError(t, err)
+	for _, ref := range []string{"refs/heads/main", "refs/heads/feature", "refs/tags/v1.0.0"} {
+		require.Equal(t, gittest.Git(t, oldDir, "rev-parse", ref), gittest.Git(t, newDir, "rev-parse", ref), ref)
+	}
+
+	// diverged refs of the new repository are never overwritten
+	gittest.Commit(t, newDir, "main", map[string]string{"README.md": "diverged"})
+	err = mirror(ctx, entry, filepath.Join(t.TempDir(), "app.git"), false)
+	require.Error(t, err)
+	require.NotEqual(t, gittest.Git(t, oldDir, "rev-parse", "main"), gittest.Git(t, newDir, "rev-parse", "main"))
+}
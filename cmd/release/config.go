@@ -1,10 +1,40 @@
 package release
 
-import "errors"
+import (
+	"errors"
+	"strings"
+
+	"github.com/jxsl13/module-migration/defaults"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
+	"github.com/jxsl13/module-migration/utils"
+)
 
 type ReleaseConfig struct {
+	CSVPath string `koanf:"csv" short:"c" description:"optional path to mapping file (.csv, .yaml, .yml or .json) for repository specific release branches and skipping"`
+
+	Comma     string `koanf:"separator" short:"s" description:"column separator character in csv"`
+	OldColumn string `koanf:"old" short:"o" description:"column name or index (starting with 0) containing the old [git] url"`
+	NewColumn string `koanf:"new" short:"n" description:"column name or index (starting with 0) containing the new [git] url"`
+
+	ResolveChains bool   `koanf:"resolve.chains" description:"resolve chained mappings (a -> b, b -> c) to their final target (a -> c)"`
+	ModuleRules   string `koanf:"module.rules"`
+
 	RemoteName string `koanf:"remote" short:"r" description:"name of the remote url"`
 	Push       bool   `koanf:"push" short:"p" description:"push tags to remote repo"`
+
+	Jobs      string `koanf:"jobs" short:"j" description:"maximum number of repositories that are processed in parallel, 0 uses the number of CPUs"`
+	HostJobs  string `koanf:"host.jobs" description:"',' separated list of per remote host limits of repositories that are processed in parallel, e.g. git.company.com=2,*=4"`
+	KeepGoing bool   `koanf:"keep.going" description:"process all repositories even if a repository failed, otherwise repositories that were not started yet are skipped after the first failure"`
+	Report    string `koanf:"report" description:"write a run report to the file path, the format is selected by the extension: .json, .xml (JUnit) or .md (Markdown)"`
+
+	comma       rune
+	fleet       fleet.Options
+	moduleRules utils.ModulePathRules
+
+	oldIdx int
+	newIdx int
 }
 
 func (c *ReleaseConfig) Validate() error {
@@ -12,5 +42,68 @@ func (c *ReleaseConfig) Validate() error {
 		return errors.New("remote name is empty")
 	}
 
+	var err error
+	c.fleet, err = fleet.ParseOptions(c.Jobs, c.HostJobs, c.RemoteName)
+	if err != nil {
+		return err
+	}
+	c.fleet.KeepGoing = c.KeepGoing
+
+	if c.Report != "" {
+		err = report.CheckPath(c.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.CSVPath == "" {
+		return nil
+	}
+
+	c.moduleRules, err = utils.ParseModulePathRules(strings.Split(c.ModuleRules, defaults.ListSeparator))
+	if err != nil {
+		return err
+	}
+
+	comma := ([]rune(c.Comma))
+	if len(comma) == 0 {
+		return errors.New("column separator is empty")
+	}
+	c.comma = comma[0]
+
+	oldIdx, newIdx, err := mapping.ColumnIndexes(c.CSVPath, c.comma, c.OldColumn, c.NewColumn)
+	if err != nil {
+		return err
+	}
+	c.oldIdx = oldIdx
+	c.newIdx = newIdx
+
 	return nil
 }
+
+// Descriptions returns the descriptions of the flags that are shared with other subcommands.
+func (c *ReleaseConfig) Descriptions() map[string]string {
+	return map[string]string{
+		"module.rules": utils.ModulePathRulesDescription,
+	}
+}
+
+func (c *ReleaseConfig) ModulePathRules() utils.ModulePathRules {
+	return c.moduleRules
+}
+
+func (c *ReleaseConfig) CommaRune() rune {
+	return c.comma
+}
+
+func (c *ReleaseConfig) OldColumnIndex() int {
+	return c.oldIdx
+}
+
+func (c *ReleaseConfig) NewColumnIndex() int {
+	return c.newIdx
+}
+
+func (c *ReleaseConfig) FleetOptions() fleet.Options {
+	return c.fleet
+}
@@ -6,9 +6,12 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
-	"sync"
+	"time"
 
 	"github.com/jxsl13/module-migration/config"
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/mapping"
+	"github.com/jxsl13/module-migration/report"
 	"github.com/jxsl13/module-migration/utils"
 	"github.com/spf13/cobra"
 )
@@ -47,7 +50,13 @@ type releaseContext struct {
 
 func (c *releaseContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
 	c.Config = &ReleaseConfig{
-		RemoteName: "origin",
+		ResolveChains: true,
+		RemoteName:    "origin",
+		Jobs:          "0",
+		KeepGoing:     true,
+		Comma:         ";", // default separator
+		OldColumn:     "0",
+		NewColumn:     "1",
 	}
 
 	runParser := config.RegisterFlags(c.Config, true, cmd)
@@ -64,6 +73,7 @@ func (c *releaseContext) PreRunE(cmd *cobra.Command) func(cmd *cobra.Command, ar
 }
 
 func (c *releaseContext) RunE(cmd *cobra.Command, args []string) (err error) {
+	started := time.Now()
 	var (
 		ctx        = c.Ctx
 		remoteName = c.Config.RemoteName
@@ -74,28 +84,77 @@ func (c *releaseContext) RunE(cmd *cobra.Command, args []string) (err error) {
 		return fmt.Errorf("failed to find git folders: %w", err)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(repoDirs))
-	for _, repoDir := range repoDirs {
-		go func(repoDir string) {
-			defer wg.Done()
-
-			err := bump(ctx, repoDir, remoteName, push)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: failed to release repo %s: %v\n", repoDir, err)
-			} else {
-				fmt.Printf("Successfully released %s\n", repoDir)
-			}
-		}(repoDir)
+	// the mapping is optional for releases
+	resolved := &mapping.Resolved{}
+	if c.Config.CSVPath != "" {
+		m, err := mapping.Load(
+			c.Config.CSVPath,
+			c.Config.OldColumnIndex(),
+			c.Config.NewColumnIndex(),
+			c.Config.CommaRune(),
+			mapping.WithResolveChains(c.Config.ResolveChains),
+			mapping.WithOutput(utils.Stdout(ctx)),
+			mapping.WithModulePaths(c.Config.ModulePathRules()),
+		)
+		if err != nil {
+			return err
+		}
+
+		resolved, err = m.ExpandRepos(ctx, repoDirs, remoteName)
+		if err != nil {
+			return err
+		}
+	}
+
+	executor := fleet.NewExecutor(c.Config.FleetOptions())
+	results := executor.Run(ctx, repoDirs, func(ctx context.Context, repoDir string) error {
+		err := bump(ctx, resolved, repoDir, remoteName, push)
+		if fleet.IsSkipped(err) {
+			fmt.Fprintf(utils.Stdout(ctx), "Skipped %s: %v\n", repoDir, err)
+		} else if err != nil {
+			fmt.Fprintf(utils.Stderr(ctx), "Error: failed to release repo %s: %v\n", repoDir, err)
+		} else {
+			fmt.Fprintf(utils.Stdout(ctx), "Successfully released %s\n", repoDir)
+		}
+		return err
+	})
+
+	err = results.PrintSummary(os.Stdout, c.RootPath)
+	if err != nil {
+		return err
 	}
-	wg.Wait()
 
+	if c.Config.Report != "" {
+		err = report.New(cmd.Name(), c.RootPath, started, results).WriteFile(c.Config.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = results.Err()
+	if err != nil {
+		// the errors of the repositories were already printed
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return err
+	}
 	return nil
 }
 
-func bump(ctx context.Context, repoDir, remoteName string, push bool) error {
+func bump(ctx context.Context, resolved *mapping.Resolved, repoDir, remoteName string, push bool) error {
+	repoUrl, err := utils.GitRemoteUrl(ctx, repoDir, remoteName)
+	if err != nil {
+		return err
+	}
+
+	entry, _ := resolved.LookupAny(repoUrl)
+	if entry.Skip {
+		return fleet.ErrSkipped
+	}
 
-	err := utils.GitBumpVersionTag(ctx, repoDir, remoteName, false, false, true)
+	err = fleet.RunStep(ctx, "tag", func() error {
+		return utils.GitBumpVersionTag(ctx, repoDir, remoteName, entry.DefaultBranch, false, false, true)
+	})
 	if err != nil {
 		return err
 	}
@@ -104,5 +163,7 @@ func bump(ctx context.Context, repoDir, remoteName string, push bool) error {
 		return nil
 	}
 
-	return utils.GitPushTags(ctx, repoDir, remoteName)
+	return fleet.RunStep(ctx, "push", func() error {
+		return utils.GitPushTags(ctx, repoDir, remoteName)
+	})
 }
@@ -59,6 +59,12 @@ type Validatable interface {
 	Validate() error
 }
 
+// Describer provides descriptions of config keys that are shared between configs,
+// which cannot be referenced from a description struct tag.
+type Describer interface {
+	Descriptions() map[string]string
+}
+
 // Parse takes every object and is able to fill and validate that object depending on config file, env file and flag values.
 // https://github.com/knadh/koanf
 // Your passed struct must define . delimited koanf struct tags in order to match env/.env and flag values to your struct.
@@ -110,6 +116,14 @@ func RegisterFlags[T any](config *T, persistent bool, app *cobra.Command, option
 		ct = ct.Elem()
 	}
 
+	var (
+		a            any = config
+		descriptions map[string]string
+	)
+	if d, ok := a.(Describer); ok {
+		descriptions = d.Descriptions()
+	}
+
 	defaultMap, _ := maps.Flatten(defaults.All(), nil, op.delimiter)
 	maxKeyLen := maxKeyLen(defaultMap)
 	padding := maxKeyLen + len(op.envPrefix) + 1
@@ -127,7 +141,10 @@ func RegisterFlags[T any](config *T, persistent bool, app *cobra.Command, option
 		}
 		v := defaultMap[key]
 
-		desc := sTag.Get(op.descriptionTag)
+		desc, found := descriptions[key]
+		if !found {
+			desc = sTag.Get(op.descriptionTag)
+		}
 		short := sTag.Get(op.shortTag)
 		flag := sTag.Get(op.flagTag)
 
@@ -244,7 +261,6 @@ func RegisterFlags[T any](config *T, persistent bool, app *cobra.Command, option
 			return err
 		}
 
-		var a any = config
 		if v, ok := a.(Validatable); ok {
 			return v.Validate()
 		}
@@ -6,9 +6,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
-
-	giturls "github.com/whilp/git-urls"
 )
 
 func Header(filePath string, commaRune rune) ([]string, error) {
@@ -29,11 +26,19 @@ func Header(filePath string, commaRune rune) ([]string, error) {
 	return record, nil
 }
 
-// returns source and target urls as map
-func NewReplacerFromCSV(filePath string, oldColumn, newColumn int, commaRune rune) (gitUrlMap, moduleMap map[string]string, err error) {
+// Row is a single old -> new mapping row of a csv file
+type Row struct {
+	// Line is the line number of the row in the csv file, starting with 1
+	Line int
+	Old  string
+	New  string
+}
+
+// ReadRows returns all rows except for the header row.
+func ReadRows(filePath string, oldColumn, newColumn int, commaRune rune) ([]Row, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer f.Close()
 
@@ -45,59 +50,32 @@ func NewReplacerFromCSV(filePath string, oldColumn, newColumn int, commaRune run
 	r := csv.NewReader(f)
 	r.Comma = commaRune
 	r.ReuseRecord = true
-	gitUrlMap = make(map[string]string, 512)
-	moduleMap = make(map[string]string, 512)
-	row := 0
+	rows := make([]Row, 0, 512)
+	header := true
 	for {
 		record, err := r.Read()
 		if errors.Is(err, io.EOF) {
 			break
 		}
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		if len(record) <= maxIndex {
-			return nil, nil, fmt.Errorf("record %v has no index %d", record, maxIndex)
+			return nil, fmt.Errorf("record %v has no index %d", record, maxIndex)
 		}
 
-		if row == 0 {
-			row++
+		if header {
+			header = false
 			continue
 		}
 
-		oldUrl := record[oldColumn]
-		newUrl := record[newColumn]
-
-		if oldUrl == "" || newUrl == "" {
-			continue
-		}
-
-		o, err := giturls.Parse(oldUrl)
-		if err != nil {
-			return nil, nil, fmt.Errorf("invalid old url: %s", oldUrl)
-		}
-
-		n, err := giturls.Parse(newUrl)
-		if err != nil {
-			return nil, nil, fmt.Errorf("invalid new url: %s", newUrl)
-		}
-
-		gitUrlOld := strings.TrimLeft(o.String(), "/")
-		gitUrlNew := strings.TrimLeft(n.String(), "/")
-
-		gitUrlMap[gitUrlOld] = gitUrlNew
-
-		// remove scheme only for import mapping
-		o.Scheme = ""
-		o.User = nil
-		n.Scheme = ""
-		n.User = nil
-
-		oldModuleUrl := strings.TrimSuffix(strings.TrimLeft(o.String(), "/"), ".git")
-		newModuleUrl := strings.TrimSuffix(strings.TrimLeft(n.String(), "/"), ".git")
-		moduleMap[oldModuleUrl] = newModuleUrl
-		row++
+		line, _ := r.FieldPos(oldColumn)
+		rows = append(rows, Row{
+			Line: line,
+			Old:  record[oldColumn],
+			New:  record[newColumn],
+		})
 	}
-	return gitUrlMap, moduleMap, nil
+	return rows, nil
 }
@@ -0,0 +1,245 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jxsl13/module-migration/utils"
+)
+
+// Options configure the parallelism of an Executor.
+type Options struct {
+	// Jobs is the maximum number of repositories that are processed in parallel
+	Jobs int
+	// HostLimits additionally limits the number of parallel repositories per remote host
+	HostLimits HostLimits
+	// RemoteName is the git remote that is used to determine the host of a repository
+	RemoteName string
+	// RemoteUrls are the remote urls of repositories that do not exist yet,
+	// e.g. because they are cloned by the executed function
+	RemoteUrls map[string]string
+	// KeepGoing processes all repositories even if one of them failed.
+	// Otherwise repositories that did not start yet are skipped with ErrStopped.
+	KeepGoing bool
+
+	// State persists the progress of every repository in case it is set.
+	State *State
+	// Command is the name of the command whose progress is persisted
+	Command string
+	// Resume selects which repositories are processed again
+	Resume ResumeMode
+}
+
+// Result is the outcome of processing a single repository.
+type Result struct {
+	RepoDir string
+	Err     error
+
+	// Duration is the processing time without the time spent waiting for a free job
+	Duration time.Duration
+	// Steps are all steps that were recorded with RunStep
+	Steps []Step
+	// Files are all files that were recorded with Touched, relative to RepoDir
+	Files []string
+}
+
+// Func processes a single repository. All output must be written to
+// utils.Stdout(ctx) and utils.Stderr(ctx) in order to keep it together.
+type Func func(ctx context.Context, repoDir string) error
+
+// Executor processes repositories concurrently with bounded parallelism.
+type Executor struct {
+	opts Options
+
+	jobs chan struct{}
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+
+	stopped atomic.Bool
+}
+
+// NewExecutor creates a new executor. A non-positive number of jobs processes one repository at a time.
+func NewExecutor(opts Options) *Executor {
+	if opts.Jobs <= 0 {
+		opts.Jobs = 1
+	}
+	return &Executor{
+		opts:  opts,
+		jobs:  make(chan struct{}, opts.Jobs),
+		hosts: make(map[string]chan struct{}),
+	}
+}
+
+// Run processes all repositories and returns their results in the order of repoDirs.
+// The buffered output of every repository is written to os.Stdout and os.Stderr
+// as soon as the repository and all of its predecessors are done, so the output
+// does not depend on the order in which the repositories finish.
+func (e *Executor) Run(ctx context.Context, repoDirs []string, fn Func) Results {
+	var (
+		results = make(Results, len(repoDirs))
+		outputs = make([]*bufferedOutput, len(repoDirs))
+		done    = make([]chan struct{}, len(repoDirs))
+	)
+
+	for idx, repoDir := range repoDirs {
+		outputs[idx] = &bufferedOutput{}
+		done[idx] = make(chan struct{})
+
+		go func(idx int, repoDir string) {
+			defer close(done[idx])
+
+			out := outputs[idx]
+			rec := &record{}
+			ctx := withRecord(utils.WithOutput(ctx, out.writer(os.Stdout), out.writer(os.Stderr)), rec)
+
+			r := Result{RepoDir: repoDir}
+			r.Err = e.run(ctx, repoDir, fn, &r.Duration)
+			r.Steps = rec.steps
+			r.Files = rec.files
+			results[idx] = r
+		}(idx, repoDir)
+	}
+
+	for idx := range repoDirs {
+		<-done[idx]
+		outputs[idx].flush()
+	}
+	return results
+}
+
+func (e *Executor) run(ctx context.Context, repoDir string, fn Func, duration *time.Duration) error {
+	// looking up the remote host executes git, so it is bounded by the number of jobs as well
+	release, err := acquire(ctx, e.jobs)
+	if err != nil {
+		return err
+	}
+	host := e.host(ctx, repoDir)
+	release()
+
+	if host != nil {
+		release, err := acquire(ctx, host)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	release, err = acquire(ctx, e.jobs)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if e.stopped.Load() {
+		return ErrStopped
+	}
+
+	if e.opts.State != nil {
+		rec := recordFrom(ctx)
+		rec.done, rec.values, err = e.opts.State.start(e.opts.Command, repoDir, e.opts.Resume)
+		if err != nil {
+			return err
+		}
+		rec.state = e.opts.State
+		rec.command = e.opts.Command
+		rec.repoDir = repoDir
+	}
+
+	started := time.Now()
+	err = fn(ctx, repoDir)
+	*duration = time.Since(started)
+
+	if e.opts.State != nil {
+		err = errors.Join(err, e.opts.State.finish(e.opts.Command, repoDir, err, recordFrom(ctx).files))
+	}
+	if !e.opts.KeepGoing && err != nil && !IsSkipped(err) {
+		e.stopped.Store(true)
+	}
+	return err
+}
+
+func acquire(ctx context.Context, sem chan struct{}) (release func(), err error) {
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// host returns the semaphore of the remote host of the repository or nil in case the host is not limited.
+func (e *Executor) host(ctx context.Context, repoDir string) chan struct{} {
+	if len(e.opts.HostLimits) == 0 {
+		return nil
+	}
+
+	url, found := e.opts.RemoteUrls[repoDir]
+	if !found {
+		var err error
+		url, err = utils.GitRemoteUrl(ctx, repoDir, e.opts.RemoteName)
+		if err != nil {
+			return nil
+		}
+	}
+
+	host, err := utils.ToHost(url)
+	if err != nil {
+		return nil
+	}
+
+	limit, ok := e.opts.HostLimits.Limit(host)
+	if !ok {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sem, found := e.hosts[host]
+	if !found {
+		sem = make(chan struct{}, limit)
+		e.hosts[host] = sem
+	}
+	return sem
+}
+
+// bufferedOutput keeps the order of writes to stdout and stderr.
+type bufferedOutput struct {
+	mu     sync.Mutex
+	chunks []chunk
+}
+
+type chunk struct {
+	w    io.Writer
+	data []byte
+}
+
+func (o *bufferedOutput) writer(w io.Writer) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.chunks = append(o.chunks, chunk{w: w, data: bytes.Clone(p)})
+		return len(p), nil
+	})
+}
+
+func (o *bufferedOutput) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, c := range o.chunks {
+		_, _ = c.w.Write(c.data)
+	}
+	o.chunks = nil
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,194 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutorRun(t *testing.T) {
+	var (
+		running int32
+		maximum int32
+	)
+
+	repoDirs := []string{"a", "b", "c", "d", "e", "f"}
+	e := NewExecutor(Options{Jobs: 2, KeepGoing: true})
+	results := e.Run(context.Background(), repoDirs, func(ctx context.Context, repoDir string) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maximum)
+			if n <= m || atomic.CompareAndSwapInt32(&maximum, m, n) {
+				break
+			}
+		}
+
+		// later repositories finish first
+		time.Sleep(time.Duration(len(repoDirs)-int(repoDir[0]-'a')) * time.Millisecond)
+		fmt.Fprintf(utils.Stdout(ctx), "%s\n", repoDir)
+		if repoDir == "c" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	require.LessOrEqual(t, maximum, int32(2))
+	require.Len(t, results, len(repoDirs))
+	for idx, r := range results {
+		require.Equal(t, repoDirs[idx], r.RepoDir)
+		if r.RepoDir == "c" {
+			require.Error(t, r.Err)
+		} else {
+			require.NoError(t, r.Err)
+		}
+	}
+}
+
+func TestExecutorStop(t *testing.T) {
+	repoDirs := []string{"a", "b", "c", "d"}
+	e := NewExecutor(Options{Jobs: 1})
+	results := e.Run(context.Background(), repoDirs, func(ctx context.Context, repoDir string) error {
+		return errors.New("failed")
+	})
+
+	require.Equal(t, 1, results.Count(StatusFailed))
+	require.Equal(t, len(repoDirs)-1, results.Count(StatusSkipped))
+	require.Error(t, results.Err())
+}
+
+func TestParseHostLimits(t *testing.T) {
+	limits, err := ParseHostLimits([]string{"Git.Company.com=2", " *=4 ", ""})
+	require.NoError(t, err)
+
+	n, ok := limits.Limit("git.company.com")
+	require.True(t, ok)
+	require.Equal(t, 2, n)
+
+	n, ok = limits.Limit("github.com")
+	require.True(t, ok)
+	require.Equal(t, 4, n)
+
+	for _, invalid := range []string{"git.company.com", "git.company.com=0", "=2", "git.company.com=x"} {
+		_, err = ParseHostLimits([]string{invalid})
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestExecutorResume(t *testing.T) {
+	rootPath := t.TempDir()
+	repoDirs := []string{filepath.Join(rootPath, "a"), filepath.Join(rootPath, "b")}
+
+	run := func(mode ResumeMode, failStep string) (Results, []string) {
+		state, err := LoadState(context.Background(), rootPath)
+		require.NoError(t, err)
+
+		var (
+			mu       sync.Mutex
+			executed []string
+		)
+		e := NewExecutor(Options{Jobs: 1, KeepGoing: true, State: state, Command: "migrate", Resume: mode})
+		results := e.Run(context.Background(), repoDirs, func(ctx context.Context, repoDir string) error {
+			for _, step := range []string{"write", "tidy", "build"} {
+				err := RunStep(ctx, step, func() error {
+					mu.Lock()
+					executed = append(executed, filepath.Base(repoDir)+":"+step)
+					mu.Unlock()
+					if filepath.Base(repoDir) == "b" && step == failStep {
+						return errors.New("failed")
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		sort.Strings(executed)
+		return results, executed
+	}
+
+	results, _ := run(Resume, "tidy")
+	require.Equal(t, 1, results.Count(StatusFailed))
+
+	// only the failed step and its successors are executed again
+	results, executed := run(RetryFailed, "")
+	require.Equal(t, []string{"b:build", "b:tidy"}, executed)
+	require.ErrorIs(t, results[0].Err, ErrNotFailed)
+	require.NoError(t, results[1].Err)
+
+	results, executed = run(Resume, "")
+	require.Empty(t, executed)
+	require.Equal(t, 2, results.Count(StatusSkipped))
+
+	_, executed = run(Force, "")
+	require.Len(t, executed, 6)
+
+	// succeeded repositories are processed again, interrupted ones resume
+	results, _ = run(Repeat, "build")
+	require.Equal(t, 1, results.Count(StatusFailed))
+	_, executed = run(Repeat, "")
+	require.Equal(t, []string{"a:build", "a:tidy", "a:write", "b:build"}, executed)
+}
+
+func TestStatePath(t *testing.T) {
+	ctx := context.Background()
+	rootPath := t.TempDir()
+
+	path, err := StatePath(ctx, rootPath)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(rootPath, "."+StateFileName), path)
+
+	// the state file must not be a local change of the repository
+	_, err = utils.ExecuteQuietPathApplicationWithOutput(ctx, rootPath, "git", "init")
+	require.NoError(t, err)
+
+	path, err = StatePath(ctx, rootPath)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(rootPath, ".git"), filepath.Dir(path))
+}
+
+func TestRunStepValue(t *testing.T) {
+	rootPath := t.TempDir()
+	repoDir := filepath.Join(rootPath, "a")
+
+	run := func(computed []string, fail bool) ([]string, error) {
+		state, err := LoadState(context.Background(), rootPath)
+		require.NoError(t, err)
+
+		var value []string
+		e := NewExecutor(Options{Jobs: 1, State: state, Command: "migrate"})
+		results := e.Run(context.Background(), []string{repoDir}, func(ctx context.Context, repoDir string) (err error) {
+			value, err = RunStepValue(ctx, "write", func() ([]string, error) {
+				return computed, nil
+			})
+			if err != nil {
+				return err
+			}
+			if fail {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		return value, results[0].Err
+	}
+
+	value, err := run([]string{"example.com/a@v1.0.0"}, true)
+	require.Error(t, err)
+	require.Equal(t, []string{"example.com/a@v1.0.0"}, value)
+
+	// the value can no longer be computed after the step completed
+	value, err = run(nil, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com/a@v1.0.0"}, value)
+}
@@ -0,0 +1,78 @@
+package fleet
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/jxsl13/module-migration/defaults"
+)
+
+// AnyHost is the host of a HostLimits entry that applies to all hosts without an explicit limit.
+const AnyHost = "*"
+
+// HostLimits maps lower case remote host names to the maximum number of
+// repositories of that host that are processed in parallel.
+type HostLimits map[string]int
+
+// ParseJobs parses the maximum number of repositories that are processed in parallel.
+// Zero selects the number of CPUs.
+func ParseJobs(s string) (int, error) {
+	jobs, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || jobs < 0 {
+		return 0, fmt.Errorf("invalid number of jobs, expected a non-negative integer: %s", s)
+	}
+	if jobs == 0 {
+		jobs = runtime.NumCPU()
+	}
+	return jobs, nil
+}
+
+// ParseHostLimits parses a list of host=limit entries, e.g. git.company.com=2 or *=4
+func ParseHostLimits(entries []string) (HostLimits, error) {
+	limits := make(HostLimits, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		host, limit, found := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if !found || host == "" || err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid host limit, expected <host>=<positive limit>: %s", entry)
+		}
+		limits[host] = n
+	}
+	return limits, nil
+}
+
+// Limit returns the limit of the host and false in case the host is not limited.
+func (l HostLimits) Limit(host string) (int, bool) {
+	if n, ok := l[host]; ok {
+		return n, true
+	}
+	n, ok := l[AnyHost]
+	return n, ok
+}
+
+// ParseOptions parses the --jobs and --host-jobs flag values.
+func ParseOptions(jobs, hostJobs, remoteName string) (Options, error) {
+	n, err := ParseJobs(jobs)
+	if err != nil {
+		return Options{}, err
+	}
+
+	limits, err := ParseHostLimits(strings.Split(hostJobs, defaults.ListSeparator))
+	if err != nil {
+		return Options{}, err
+	}
+
+	return Options{
+		Jobs:       n,
+		HostLimits: limits,
+		RemoteName: remoteName,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// ErrSkipped is the reason of repositories that are marked to be skipped in the mapping file.
+var ErrSkipped = errors.New("skipped by mapping")
+
+// ErrStopped is the reason of repositories that were not processed
+// because a previous repository failed and the executor does not keep going.
+var ErrStopped = errors.New("not processed: stopped after previous failure")
+
+// ErrNothingToDo is the reason of repositories that do not need to be processed by a command.
+// It is usually wrapped together with the actual reason.
+var ErrNothingToDo = errors.New("nothing to do")
+
+// Status is the outcome category of a processed repository
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+)
+
+// IsSkipped returns true for repositories that are skipped by the mapping,
+// that were not processed after a previous failure, that are not processed
+// again according to the state of a previous run or that have nothing to do.
+func IsSkipped(err error) bool {
+	return errors.Is(err, ErrSkipped) ||
+		errors.Is(err, ErrNothingToDo) ||
+		errors.Is(err, ErrStopped) ||
+		errors.Is(err, ErrDone) ||
+		errors.Is(err, ErrNotFailed)
+}
+
+// Status returns the outcome category of the result.
+func (r Result) Status() Status {
+	switch {
+	case r.Err == nil:
+		return StatusSucceeded
+	case IsSkipped(r.Err):
+		return StatusSkipped
+	default:
+		return StatusFailed
+	}
+}
+
+// Reason returns the error message of failed and skipped repositories.
+func (r Result) Reason() string {
+	if r.Err == nil {
+		return ""
+	}
+	// keep the table readable
+	return strings.Join(strings.Fields(r.Err.Error()), " ")
+}
+
+// Results are the results of all repositories in the order they were passed to the executor.
+type Results []Result
+
+// Count returns the number of results with the passed status.
+func (rs Results) Count(status Status) int {
+	n := 0
+	for _, r := range rs {
+		if r.Status() == status {
+			n++
+		}
+	}
+	return n
+}
+
+// PrintSummary writes a table of all repositories, their status and the reason
+// for failed and skipped repositories. Repository paths are printed relative to rootPath.
+func (rs Results) PrintSummary(w io.Writer, rootPath string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nREPOSITORY\tSTATUS\tREASON")
+	for _, r := range rs {
+		repo, err := filepath.Rel(rootPath, r.RepoDir)
+		if err != nil {
+			repo = r.RepoDir
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", repo, r.Status(), r.Reason())
+	}
+	fmt.Fprintf(tw, "\n%d succeeded, %d failed, %d skipped\n",
+		rs.Count(StatusSucceeded),
+		rs.Count(StatusFailed),
+		rs.Count(StatusSkipped),
+	)
+	return tw.Flush()
+}
+
+// Err returns an error in case any repository failed.
+func (rs Results) Err() error {
+	failed := rs.Count(StatusFailed)
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d repositories failed", failed, len(rs))
+}
@@ -0,0 +1,237 @@
+package fleet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jxsl13/module-migration/utils"
+)
+
+// StateFileName is the name of the state file. It is kept in the git directory in case the
+// root directory is part of a repository, so it never shows up as an untracked file.
+const StateFileName = "module-migration-state.json"
+
+var (
+	// ErrDone is the reason of repositories that already succeeded in a previous run.
+	ErrDone = errors.New("already succeeded in a previous run, use --force to restart")
+	// ErrNotFailed is the reason of repositories that are not reprocessed by --retry-failed.
+	ErrNotFailed = errors.New("did not fail in a previous run")
+)
+
+// ResumeMode selects which repositories are processed again.
+type ResumeMode int
+
+const (
+	// Resume processes all repositories that did not succeed yet and skips their completed steps.
+	Resume ResumeMode = iota
+	// RetryFailed only processes repositories that failed and skips their completed steps.
+	RetryFailed
+	// Force processes all repositories from scratch.
+	Force
+	// Repeat processes all repositories again, but only skips the completed steps of
+	// repositories that did not succeed yet, e.g. for commands that are run periodically.
+	Repeat
+)
+
+// State persists the progress of every repository per command in a json file,
+// so interrupted runs can be resumed.
+type State struct {
+	path     string
+	rootPath string
+
+	mu           sync.Mutex
+	Repositories map[string]map[string]*Progress `json:"repositories"`
+}
+
+// Progress is the progress of a single repository of a single command.
+type Progress struct {
+	Status  Status    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Steps   []string  `json:"steps,omitempty"`
+	Files   []string  `json:"files,omitempty"`
+	Updated time.Time `json:"updated"`
+
+	// Values are the results of completed steps that are needed by later steps
+	Values map[string]json.RawMessage `json:"values,omitempty"`
+}
+
+// LoadState reads the state file of the root directory or returns an empty state in case it does not exist.
+func LoadState(ctx context.Context, rootPath string) (*State, error) {
+	path, err := StatePath(ctx, rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &State{
+		path:         path,
+		rootPath:     rootPath,
+		Repositories: make(map[string]map[string]*Progress),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", s.path, err)
+	}
+	if s.Repositories == nil {
+		s.Repositories = make(map[string]map[string]*Progress)
+	}
+	return s, nil
+}
+
+// StatePath returns the path of the state file of the root directory. The state file is kept
+// in the git directory in case the root directory is part of a repository, otherwise the
+// state file would be reported as a local change and committed with the migration.
+func StatePath(ctx context.Context, rootPath string) (string, error) {
+	rootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	gitDir, err := utils.GitCommonDir(ctx, rootPath)
+	if err != nil {
+		// not part of a repository, the root directory is no working tree
+		return filepath.Join(rootPath, "."+StateFileName), nil
+	}
+
+	// one repository may contain multiple root directories
+	sum := sha256.Sum256([]byte(rootPath))
+	return filepath.Join(gitDir, fmt.Sprintf("%s-%x", StateFileName, sum[:4])), nil
+}
+
+// key returns the repository path relative to the root directory.
+func (s *State) key(repoDir string) string {
+	rel, err := filepath.Rel(s.rootPath, repoDir)
+	if err != nil {
+		return repoDir
+	}
+	return filepath.ToSlash(rel)
+}
+
+// start returns the completed steps of the repository and their values or an error in case
+// the repository must not be processed in the selected mode.
+func (s *State) start(command, repoDir string, mode ResumeMode) (map[string]bool, map[string]json.RawMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.key(repoDir)
+	commands, found := s.Repositories[key]
+	if !found {
+		commands = make(map[string]*Progress)
+		s.Repositories[key] = commands
+	}
+
+	p, found := commands[command]
+	switch {
+	case !found || mode == Force || mode == Repeat && p.Status == StatusSucceeded:
+		p = &Progress{}
+		commands[command] = p
+	case mode == RetryFailed && p.Status != StatusFailed:
+		return nil, nil, ErrNotFailed
+	case p.Status == StatusSucceeded:
+		return nil, nil, ErrDone
+	}
+
+	done := make(map[string]bool, len(p.Steps))
+	for _, step := range p.Steps {
+		done[step] = true
+	}
+
+	p.Status = statusRunning
+	p.Error = ""
+	p.Updated = time.Now()
+	return done, maps.Clone(p.Values), s.save()
+}
+
+const statusRunning Status = "running"
+
+// stepDone marks a step of the repository as completed and persists its value in case it is not nil.
+func (s *State) stepDone(command, repoDir, step string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.Repositories[s.key(repoDir)][command]
+	if value != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to persist the value of step %s: %w", step, err)
+		}
+		if p.Values == nil {
+			p.Values = make(map[string]json.RawMessage)
+		}
+		p.Values[step] = data
+	}
+	p.Steps = append(p.Steps, step)
+	p.Updated = time.Now()
+	return s.save()
+}
+
+// resetSteps forgets all completed steps of the repository.
+func (s *State) resetSteps(command, repoDir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.Repositories[s.key(repoDir)][command]
+	p.Steps = nil
+	p.Values = nil
+	p.Updated = time.Now()
+	return s.save()
+}
+
+// Files returns the files that were touched by the command in the repository.
+func (s *State) Files(command, repoDir string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, found := s.Repositories[s.key(repoDir)][command]
+	if !found || p.Status != StatusSucceeded {
+		return nil, false
+	}
+	return p.Files, true
+}
+
+// finish persists the final status and the touched files of the repository.
+func (s *State) finish(command, repoDir string, err error, files []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.Repositories[s.key(repoDir)][command]
+	p.Status = Result{Err: err}.Status()
+	p.Files = files
+	if err != nil {
+		p.Error = err.Error()
+	}
+	p.Updated = time.Now()
+	return s.save()
+}
+
+// save writes the state to a temporary file and renames it, so the
+// state file is never left half written.
+func (s *State) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, append(data, '\n'), 0666)
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
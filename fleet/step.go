@@ -0,0 +1,166 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jxsl13/module-migration/utils"
+)
+
+// Step is a single recorded step of processing a repository, e.g. pull, tidy or push.
+type Step struct {
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+	Err      error
+}
+
+type recordKey struct{}
+
+// record collects the steps and touched files of a single repository.
+type record struct {
+	mu    sync.Mutex
+	steps []Step
+	files []string
+
+	// state persists the completed steps in case it is set
+	state   *State
+	command string
+	repoDir string
+	// done contains the steps that were completed in a previous run
+	done map[string]bool
+	// values contains the persisted values of completed steps
+	values map[string]json.RawMessage
+}
+
+func withRecord(ctx context.Context, r *record) context.Context {
+	return context.WithValue(ctx, recordKey{}, r)
+}
+
+func recordFrom(ctx context.Context) *record {
+	r, _ := ctx.Value(recordKey{}).(*record)
+	return r
+}
+
+// RunStep executes fn and records its name, duration and error in case
+// the context belongs to a repository that is processed by an Executor.
+// Steps that were completed in a previous run are not executed again, so fn
+// must only be used for steps that change the repository or its remote.
+func RunStep(ctx context.Context, name string, fn func() error) error {
+	r := recordFrom(ctx)
+	if r.completed(ctx, name) {
+		return nil
+	}
+
+	err := r.add(name, fn)
+	if err == nil {
+		err = r.stepDone(name, nil)
+	}
+	return err
+}
+
+// RunStepValue executes fn like RunStep and persists its result together with the completed step.
+// Steps that were completed in a previous run are not executed again, but return the persisted result,
+// e.g. information that can no longer be computed after the step changed the repository.
+func RunStepValue[T any](ctx context.Context, name string, fn func() (T, error)) (value T, err error) {
+	r := recordFrom(ctx)
+	if r.completed(ctx, name) {
+		data, found := r.values[name]
+		if !found {
+			return value, nil
+		}
+		err = json.Unmarshal(data, &value)
+		if err != nil {
+			return value, fmt.Errorf("invalid persisted value of step %s: %w", name, err)
+		}
+		return value, nil
+	}
+
+	err = r.add(name, func() (err error) {
+		value, err = fn()
+		return err
+	})
+	if err == nil {
+		err = r.stepDone(name, value)
+	}
+	return value, err
+}
+
+// completed returns true in case the step was completed in a previous run.
+func (r *record) completed(ctx context.Context, name string) bool {
+	if r == nil || !r.done[name] {
+		return false
+	}
+	fmt.Fprintf(utils.Stdout(ctx), "Resume: skipping step completed in a previous run: %s\n", name)
+	return true
+}
+
+// stepDone persists the completed step and its value in case the repository has a state.
+func (r *record) stepDone(name string, value any) error {
+	if r == nil || r.state == nil {
+		return nil
+	}
+	return r.state.stepDone(r.command, r.repoDir, name, value)
+}
+
+// ResetSteps forgets all completed steps of the repository, e.g. after they were rolled back,
+// so they are executed again in the next run.
+func ResetSteps(ctx context.Context) error {
+	r := recordFrom(ctx)
+	if r == nil || r.state == nil {
+		return nil
+	}
+	r.done = nil
+	r.values = nil
+	return r.state.resetSteps(r.command, r.repoDir)
+}
+
+// Measure executes fn and records its name, duration and error like RunStep,
+// but fn is always executed. It is used for steps that only compute changes.
+func Measure(ctx context.Context, name string, fn func() error) error {
+	return recordFrom(ctx).add(name, fn)
+}
+
+func (r *record) add(name string, fn func() error) error {
+	started := time.Now()
+	err := fn()
+
+	if r != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.steps = append(r.steps, Step{
+			Name:     name,
+			Started:  started,
+			Duration: time.Since(started),
+			Err:      err,
+		})
+	}
+	return err
+}
+
+// Touched records files that were created or modified in the repository.
+// Files that were already recorded are ignored.
+func Touched(ctx context.Context, files ...string) {
+	r := recordFrom(ctx)
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range files {
+		if !slices.Contains(r.files, f) {
+			r.files = append(r.files, f)
+		}
+	}
+}
+
+// Resumed returns true in case steps of the repository were completed in a previous run.
+func Resumed(ctx context.Context) bool {
+	r := recordFrom(ctx)
+	return r != nil && len(r.done) > 0
+}
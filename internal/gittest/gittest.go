@@ -0,0 +1,79 @@
+// Package gittest creates local bare repositories that are used as git remotes in tests.
+package gittest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Git executes a git command in the directory and returns its trimmed output.
+func Git(t testing.TB, dir string, args ...string) string {
+	t.Helper()
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test",
+		"GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test",
+		"GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := c.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed in %s: %v: %s", strings.Join(args, " "), dir, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// SetIdentity sets the author and committer of commits that are created by the tested code.
+func SetIdentity(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+}
+
+// NewRemote creates an empty bare repository in a temporary directory and returns its directory.
+// The default branch is main.
+func NewRemote(t testing.TB) string {
+	t.Helper()
+	remoteDir := filepath.Join(t.TempDir(), "remote.git")
+	Git(t, "", "init", "--bare", "--initial-branch", "main", remoteDir)
+	return remoteDir
+}
+
+// Commit commits the files on top of the branch of the bare repository and pushes the commit.
+// The branch is created without history in case it does not exist. Files are relative slash separated paths.
+func Commit(t testing.TB, remoteDir, branch string, files map[string]string) {
+	t.Helper()
+	workDir := t.TempDir()
+	Git(t, workDir, "init", "--initial-branch", branch)
+	Git(t, workDir, "remote", "add", "origin", remoteDir)
+	if Git(t, workDir, "ls-remote", "origin", "refs/heads/"+branch) != "" {
+		Git(t, workDir, "fetch", "origin", branch)
+		Git(t, workDir, "reset", "--hard", "FETCH_HEAD")
+	}
+
+	for name, content := range files {
+		path := filepath.Join(workDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Git(t, workDir, "add", "--all")
+	Git(t, workDir, "commit", "--allow-empty", "-m", "commit "+branch)
+	Git(t, workDir, "push", "origin", "HEAD:refs/heads/"+branch)
+}
+
+// URL returns the file url of the directory, which is needed for shallow clones of local repositories.
+func URL(dir string) string {
+	return "file://" + filepath.ToSlash(dir)
+}
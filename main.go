@@ -6,8 +6,11 @@ import (
 	"os"
 	"os/signal"
 
+	"github.com/jxsl13/module-migration/cmd/clone"
 	"github.com/jxsl13/module-migration/cmd/commit"
+	"github.com/jxsl13/module-migration/cmd/mapping"
 	"github.com/jxsl13/module-migration/cmd/migrate"
+	"github.com/jxsl13/module-migration/cmd/mirror"
 	"github.com/jxsl13/module-migration/cmd/release"
 	"github.com/spf13/cobra"
 )
@@ -39,9 +42,14 @@ func NewRootCmd() *cobra.Command {
 
 	// register flags but defer parsing and validation of the final values
 	rootCmd.AddCommand(NewCompletionCmd(rootCmd.Name()))
+	rootCmd.AddCommand(clone.NewCloneCmd())
+	rootCmd.AddCommand(mirror.NewMirrorCmd())
 	rootCmd.AddCommand(migrate.NewMigrateCmd())
+	rootCmd.AddCommand(migrate.NewRefreshCmd())
+	rootCmd.AddCommand(migrate.NewRunCmd())
 	rootCmd.AddCommand(commit.NewCommitCmd())
 	rootCmd.AddCommand(release.NewReleaseCmd())
+	rootCmd.AddCommand(mapping.NewMappingCmd())
 	return rootCmd
 }
 
@@ -0,0 +1,176 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ResolveChains replaces the target of every entry whose target is mapped again by another entry
+// with the final target of that chain, e.g. a -> b, b -> c becomes a -> c, b -> c.
+// Cycles cannot be resolved and are returned as error. Every resolved chain is reported to w.
+func (m *Mapping) ResolveChains(w io.Writer) error {
+	bySource := make(map[string]int, len(m.Entries))
+	for idx, e := range m.Entries {
+		if _, found := bySource[e.OldModule]; !found {
+			bySource[e.OldModule] = idx
+		}
+	}
+
+	resolved := make([]Entry, len(m.Entries))
+	copy(resolved, m.Entries)
+
+	errs := make([]error, 0)
+	for idx, e := range m.Entries {
+		chain := []int{idx}
+		visited := map[string]bool{e.OldModule: true}
+		current := e
+		for {
+			nextIdx, found := bySource[current.NewModule]
+			if !found || current.OldModule == current.NewModule {
+				break
+			}
+			next := m.Entries[nextIdx]
+			if visited[next.OldModule] {
+				chain = append(chain, nextIdx)
+				errs = append(errs, fmt.Errorf("%s:%d: cyclic mapping: %s", m.Source, e.Line, m.chainString(chain)))
+				break
+			}
+			visited[next.OldModule] = true
+			chain = append(chain, nextIdx)
+			current = next
+		}
+
+		if len(chain) == 1 {
+			continue
+		}
+
+		last := m.Entries[chain[len(chain)-1]]
+		resolved[idx].NewUrl = last.NewUrl
+		resolved[idx].NewModule = last.NewModule
+		resolved[idx].Chain = make([]string, 0, len(chain)+1)
+		for _, i := range chain {
+			resolved[idx].Chain = append(resolved[idx].Chain, m.Entries[i].OldModule)
+		}
+		resolved[idx].Chain = append(resolved[idx].Chain, last.NewModule)
+		fmt.Fprintf(w, "Chain: %s\n", m.chainString(chain))
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	m.Entries = resolved
+	return nil
+}
+
+func (m *Mapping) chainString(chain []int) string {
+	modules := make([]string, 0, len(chain)+1)
+	lines := make([]string, 0, len(chain))
+	for _, i := range chain {
+		modules = append(modules, m.Entries[i].OldModule)
+		lines = append(lines, strconv.Itoa(m.Entries[i].Line))
+	}
+	modules = append(modules, m.Entries[chain[len(chain)-1]].NewModule)
+	return fmt.Sprintf("%s (lines %s)", strings.Join(modules, " -> "), strings.Join(lines, " -> "))
+}
+
+// resolveChains resolves the chains of the expanded entries, e.g. an entry that was created
+// from a rule and whose target is mapped again by another entry or rule.
+// The targets of entries that are not expanded are looked up with the match function.
+func (r *Resolved) resolveChains(w io.Writer, match func(modulePath string) (Entry, bool, error)) error {
+	// next returns the entry that maps the module path and whether it was expanded from a rule
+	next := func(modulePath string) (e Entry, rule, found bool, err error) {
+		if e, found := r.byModule[modulePath]; found {
+			return e, false, true, nil
+		}
+		e, ok, err := match(modulePath)
+		if err != nil || !ok || e.OldModule != modulePath {
+			return Entry{}, false, false, err
+		}
+		return e, true, true, nil
+	}
+
+	oldModules := make([]string, 0, len(r.byModule))
+	for oldModule := range r.byModule {
+		oldModules = append(oldModules, oldModule)
+	}
+	sort.Strings(oldModules)
+
+	errs := make([]error, 0)
+	for _, oldModule := range oldModules {
+		e := r.byModule[oldModule]
+		chain := []Entry{e}
+		visited := map[string]bool{e.OldModule: true}
+		// a rule could otherwise be applied to its own target over and over again
+		rules := make(map[int]bool)
+		cyclic := false
+		for current := e; current.OldModule != current.NewModule; {
+			n, rule, found, err := next(current.NewModule)
+			if err != nil {
+				return err
+			}
+			if !found {
+				break
+			}
+			chain = append(chain, n)
+			if visited[n.OldModule] || rule && rules[n.Line] {
+				errs = append(errs, fmt.Errorf("line %d: cyclic mapping: %s", e.Line, entryChainString(chain)))
+				cyclic = true
+				break
+			}
+			visited[n.OldModule] = true
+			if rule {
+				rules[n.Line] = true
+			}
+			current = n
+		}
+
+		if len(chain) == 1 || cyclic {
+			continue
+		}
+
+		// entries may already be resolved chains
+		modules := make([]string, 0, len(chain)+1)
+		for _, c := range chain {
+			if len(c.Chain) > 0 {
+				modules = append(modules, c.Chain[:len(c.Chain)-1]...)
+			} else {
+				modules = append(modules, c.OldModule)
+			}
+		}
+
+		last := chain[len(chain)-1]
+		e.NewUrl = last.NewUrl
+		e.NewModule = last.NewModule
+		e.Chain = append(modules, last.NewModule)
+		fmt.Fprintf(w, "Chain: %s\n", entryChainString(chain))
+
+		r.Modules[e.OldModule] = e.NewModule
+		r.byModule[e.OldModule] = e
+		if e.OldUrl != "" {
+			r.GitUrls[e.OldUrl] = e.NewUrl
+			r.byOld[identity(e.OldUrl)] = e
+			r.byNew[identity(e.NewUrl)] = e
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func entryChainString(chain []Entry) string {
+	modules := make([]string, 0, len(chain)+1)
+	lines := make([]string, 0, len(chain))
+	for _, e := range chain {
+		modules = append(modules, e.OldModule)
+		lines = append(lines, strconv.Itoa(e.Line))
+	}
+	modules = append(modules, chain[len(chain)-1].NewModule)
+	return fmt.Sprintf("%s (lines %s)", strings.Join(modules, " -> "), strings.Join(lines, " -> "))
+}
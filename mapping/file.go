@@ -0,0 +1,101 @@
+package mapping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileEntry is a single entry of a yaml or json mapping file
+type fileEntry struct {
+	Old string `yaml:"old" json:"old"`
+	New string `yaml:"new" json:"new"`
+
+	Options `yaml:",inline"`
+}
+
+// yamlFile is the structure of a yaml mapping file
+//
+//	mappings:
+//	  - old: ssh://git@git.company.com/project/repo.git
+//	    new: git@github.com:company/repo.git
+//	    default_branch: main
+//	    reviewers: [octocat]
+//	    labels: [migration]
+type yamlFile struct {
+	Mappings []yaml.Node `yaml:"mappings"`
+}
+
+// jsonFile is the structure of a json mapping file with the same keys as the yaml file
+type jsonFile struct {
+	Mappings []fileEntry `json:"mappings"`
+}
+
+// FromYAML reads a yaml mapping file.
+func FromYAML(filePath string) (*Mapping, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var f yamlFile
+	err = yaml.Unmarshal(data, &f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid yaml mapping file %s: %w", filePath, err)
+	}
+
+	m := &Mapping{
+		Source:  filePath,
+		Entries: make([]Entry, 0, len(f.Mappings)),
+	}
+	errs := make([]error, 0)
+	for _, node := range f.Mappings {
+		var fe fileEntry
+		err = node.Decode(&fe)
+		if err == nil {
+			err = m.addFileEntry(node.Line, fe)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", filePath, node.Line, err))
+		}
+	}
+	return m, errors.Join(errs...)
+}
+
+// FromJSON reads a json mapping file.
+func FromJSON(filePath string) (*Mapping, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var f jsonFile
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json mapping file %s: %w", filePath, err)
+	}
+
+	m := &Mapping{
+		Source:  filePath,
+		Entries: make([]Entry, 0, len(f.Mappings)),
+	}
+	errs := make([]error, 0)
+	for idx, fe := range f.Mappings {
+		err = m.addFileEntry(idx+1, fe)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: entry %d: %w", filePath, idx+1, err))
+		}
+	}
+	return m, errors.Join(errs...)
+}
+
+func (m *Mapping) addFileEntry(line int, fe fileEntry) error {
+	if fe.Old == "" || fe.New == "" {
+		m.Incomplete = append(m.Incomplete, line)
+		return nil
+	}
+	return m.add(line, fe.Old, fe.New, fe.Options)
+}
@@ -0,0 +1,529 @@
+package mapping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jxsl13/module-migration/csv"
+	"github.com/jxsl13/module-migration/utils"
+	giturls "github.com/whilp/git-urls"
+	"golang.org/x/mod/modfile"
+)
+
+// Options are optional per repository settings of a mapping entry or rule.
+type Options struct {
+	// Module explicitly requests a new module path instead of the one derived from the new url
+	Module string `yaml:"module,omitempty" json:"module,omitempty"`
+
+	// DefaultBranch is the target branch of the pull request and the release branch
+	DefaultBranch string   `yaml:"default_branch,omitempty" json:"default_branch,omitempty"`
+	Reviewers     []string `yaml:"reviewers,omitempty" json:"reviewers,omitempty"`
+	Labels        []string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
+	// Include and Exclude replace the globally configured regular expressions
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+
+	// Copy is a list of additional files or directories that are copied into the repository
+	Copy []string `yaml:"copy,omitempty" json:"copy,omitempty"`
+
+	// Skip excludes the repository from all subcommands
+	Skip bool `yaml:"skip,omitempty" json:"skip,omitempty"`
+}
+
+// Entry is an explicit mapping of one old git repository to one new git repository.
+type Entry struct {
+	// Line is the line number of the entry in csv and yaml files and the entry number in json files
+	Line int
+
+	OldUrl string
+	NewUrl string
+
+	OldModule string
+	NewModule string
+
+	// Chain contains all module paths from the old to the final new module path
+	// in case the entry was resolved transitively
+	Chain []string
+
+	Options
+}
+
+// Mapping contains all explicit entries and wildcard rules of a mapping file.
+type Mapping struct {
+	// Source is the file path of the mapping file
+	Source  string
+	Entries []Entry
+	Rules   []Rule
+
+	// Incomplete contains the line numbers of ignored rows with an empty old or new url
+	Incomplete []int
+
+	// modulePaths derive the module paths of the git urls
+	modulePaths utils.ModulePathRules
+	// vanity translates vanity import paths of requirements to their git host paths
+	vanity VanityTable
+	// resolveChains resolves the chains of entries that are expanded from rules
+	resolveChains bool
+}
+
+type loadOption struct {
+	resolveChains bool
+	modulePaths   utils.ModulePathRules
+	vanity        VanityTable
+	output        io.Writer
+}
+
+type LoadOption func(*loadOption)
+
+// WithResolveChains enables or disables the transitive resolution of chained mappings.
+func WithResolveChains(enable bool) LoadOption {
+	return func(lo *loadOption) {
+		lo.resolveChains = enable
+	}
+}
+
+// WithOutput reports the resolved chains to w instead of os.Stdout.
+func WithOutput(w io.Writer) LoadOption {
+	return func(lo *loadOption) {
+		lo.output = w
+	}
+}
+
+// WithModulePaths derives the module paths of all entries and rules with host specific rules.
+func WithModulePaths(rules utils.ModulePathRules) LoadOption {
+	return func(lo *loadOption) {
+		lo.modulePaths = rules
+	}
+}
+
+// WithVanity matches the wildcard rules against the git host paths of vanity import paths.
+func WithVanity(t VanityTable) LoadOption {
+	return func(lo *loadOption) {
+		lo.vanity = t
+	}
+}
+
+// Load reads a mapping file. The format is selected by the file extension:
+// .yaml and .yml files are parsed as yaml, .json files as json and all other files as csv.
+// The column indexes and the separator are only used for csv files.
+// Invalid rows are collected and returned as joined error together with all valid rows.
+// Chained mappings are resolved to their final target unless disabled with WithResolveChains(false).
+func Load(filePath string, oldColumn, newColumn int, commaRune rune, options ...LoadOption) (m *Mapping, err error) {
+	op := loadOption{
+		resolveChains: true,
+		output:        os.Stdout,
+	}
+	for _, o := range options {
+		o(&op)
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		m, err = FromYAML(filePath)
+	case ".json":
+		m, err = FromJSON(filePath)
+	default:
+		m, err = FromCSV(filePath, oldColumn, newColumn, commaRune)
+	}
+	if m != nil {
+		m.vanity = op.vanity
+		m.resolveChains = op.resolveChains
+	}
+	if m != nil && len(op.modulePaths) > 0 {
+		err = errors.Join(err, m.DeriveModulePaths(op.modulePaths))
+	}
+	if err != nil || !op.resolveChains {
+		return m, err
+	}
+
+	return m, m.ResolveChains(op.output)
+}
+
+// DeriveModulePaths derives the module paths of all entries and rules again with the host specific rules.
+// Explicitly requested module paths are kept.
+func (m *Mapping) DeriveModulePaths(rules utils.ModulePathRules) error {
+	m.modulePaths = rules
+
+	errs := make([]error, 0)
+	for idx := range m.Entries {
+		e := &m.Entries[idx]
+		derived, err := newEntry(rules, e.OldUrl, e.NewUrl)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", m.Source, e.Line, err))
+			continue
+		}
+		e.OldModule = derived.OldModule
+		if e.Module == "" {
+			e.NewModule = derived.NewModule
+		}
+	}
+
+	for idx := range m.Rules {
+		err := m.Rules[idx].derive(rules)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", m.Source, m.Rules[idx].Line, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsCSV returns true in case Load parses the file as csv.
+func IsCSV(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml", ".json":
+		return false
+	default:
+		return true
+	}
+}
+
+// FromCSV reads all mapping rows of a csv file.
+// Rows containing a '*' in their old url are handled as wildcard rules.
+func FromCSV(filePath string, oldColumn, newColumn int, commaRune rune) (*Mapping, error) {
+	rows, err := csv.ReadRows(filePath, oldColumn, newColumn, commaRune)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mapping{
+		Source:  filePath,
+		Entries: make([]Entry, 0, len(rows)),
+	}
+	errs := make([]error, 0)
+	for _, row := range rows {
+		if row.Old == "" || row.New == "" {
+			m.Incomplete = append(m.Incomplete, row.Line)
+			continue
+		}
+
+		err = m.add(row.Line, row.Old, row.New, Options{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", filePath, row.Line, err))
+		}
+	}
+	return m, errors.Join(errs...)
+}
+
+func (m *Mapping) add(line int, oldUrl, newUrl string, o Options) error {
+	if IsRule(oldUrl) {
+		if o.Module != "" {
+			return errors.New("an explicit module path cannot be used for wildcard rules")
+		}
+		r, err := NewRule(oldUrl, newUrl)
+		if err != nil {
+			return err
+		}
+		r.Line = line
+		r.Options = o
+		m.Rules = append(m.Rules, r)
+		return nil
+	}
+
+	e, err := NewEntry(oldUrl, newUrl)
+	if err != nil {
+		return err
+	}
+	e.Line = line
+	e.Options = o
+	if o.Module != "" {
+		e.NewModule = o.Module
+	}
+	m.Entries = append(m.Entries, e)
+	return nil
+}
+
+// NewEntry derives the git urls and module paths from an old and a new git url.
+func NewEntry(oldUrl, newUrl string) (Entry, error) {
+	return newEntry(nil, oldUrl, newUrl)
+}
+
+func newEntry(rules utils.ModulePathRules, oldUrl, newUrl string) (Entry, error) {
+	o, err := giturls.Parse(oldUrl)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid old url: %s", oldUrl)
+	}
+
+	n, err := giturls.Parse(newUrl)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid new url: %s", newUrl)
+	}
+
+	e := Entry{
+		OldUrl: strings.TrimLeft(o.String(), "/"),
+		NewUrl: strings.TrimLeft(n.String(), "/"),
+	}
+
+	// remove scheme only for import mapping
+	e.OldModule, err = rules.ToModuleUrl(oldUrl)
+	if err != nil {
+		return Entry{}, err
+	}
+	e.NewModule, err = rules.ToModuleUrl(newUrl)
+	if err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// Resolved is a mapping that was expanded against a set of repositories.
+type Resolved struct {
+	// GitUrls maps old git urls to new git urls
+	GitUrls map[string]string
+	// Modules maps old module paths to new module paths
+	Modules map[string]string
+
+	// entries by repository identity of their old and new git urls
+	byOld map[string]Entry
+	byNew map[string]Entry
+	// entries by old module path
+	byModule map[string]Entry
+}
+
+func newResolved(size int) *Resolved {
+	return &Resolved{
+		GitUrls:  make(map[string]string, size),
+		Modules:  make(map[string]string, size),
+		byOld:    make(map[string]Entry, size),
+		byNew:    make(map[string]Entry, size),
+		byModule: make(map[string]Entry, size),
+	}
+}
+
+// identity returns the canonical repository identity of the git url, see utils.RepoIdentity.
+func identity(gitUrl string) string {
+	id, err := utils.RepoIdentity(gitUrl)
+	if err != nil {
+		return gitUrl
+	}
+	return id
+}
+
+// add adds the entry and reports ignored duplicate repositories to w.
+func (r *Resolved) add(w io.Writer, gitUrl string, e Entry) {
+	if gitUrl != "" {
+		id := identity(gitUrl)
+		if prev, found := r.byOld[id]; found {
+			fmt.Fprintf(w, "Identity: line %d: %s is the same repository as %s in line %d (%s), line %d is ignored\n", e.Line, gitUrl, prev.OldUrl, prev.Line, id, e.Line)
+			return
+		}
+
+		r.GitUrls[gitUrl] = e.NewUrl
+		r.byOld[id] = e
+		r.byNew[identity(e.NewUrl)] = e
+	}
+	r.Modules[e.OldModule] = e.NewModule
+	r.byModule[e.OldModule] = e
+}
+
+// Lookup returns the entry of an old git url. All url forms of the same repository are equal.
+func (r *Resolved) Lookup(gitUrl string) (Entry, bool) {
+	e, found := r.byOld[identity(gitUrl)]
+	return e, found
+}
+
+// LookupTarget returns the entry of a new git url. All url forms of the same repository are equal.
+func (r *Resolved) LookupTarget(gitUrl string) (Entry, bool) {
+	e, found := r.byNew[identity(gitUrl)]
+	return e, found
+}
+
+// LookupAny returns the entry of either an old or a new git url.
+func (r *Resolved) LookupAny(gitUrl string) (Entry, bool) {
+	e, found := r.Lookup(gitUrl)
+	if found {
+		return e, true
+	}
+	return r.LookupTarget(gitUrl)
+}
+
+// Expand returns the git url and module path mappings of all explicit entries
+// and of all rules that match any of the passed git urls or module paths.
+// Explicit entries take precedence over rules and earlier rules take precedence over later ones.
+// Matched rules and ignored duplicate repositories are reported to the standard output of the context.
+// Chains that are created by expanded rules are resolved in case chains were resolved by Load.
+func (m *Mapping) Expand(ctx context.Context, gitUrls, modulePaths []string) (*Resolved, error) {
+	w := utils.Stdout(ctx)
+	resolved := newResolved(len(m.Entries) + len(gitUrls))
+
+	for _, e := range m.Entries {
+		resolved.add(w, e.OldUrl, e)
+	}
+
+	if len(m.Rules) == 0 {
+		return resolved, nil
+	}
+
+	for _, gitUrl := range uniqueSorted(gitUrls) {
+		if _, found := resolved.Lookup(gitUrl); found {
+			continue
+		}
+
+		oldModule, err := m.modulePaths.ToModuleUrl(gitUrl)
+		if err != nil {
+			return nil, err
+		}
+		if _, found := resolved.Modules[oldModule]; found {
+			continue
+		}
+
+		for _, r := range m.Rules {
+			e, ok, err := r.Match(oldModule)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+
+			fmt.Fprintf(w, "Rule: %s -> %s (line %d: %s -> %s)\n", gitUrl, e.NewUrl, r.Line, r.Old, r.New)
+			e.OldUrl = gitUrl
+			resolved.add(w, gitUrl, e)
+			break
+		}
+	}
+
+	for _, modulePath := range uniqueSorted(modulePaths) {
+		// the rules match the module paths that are derived from git urls
+		if hostPath, ok := m.vanity.ToHostPath(modulePath); ok {
+			modulePath = hostPath
+		}
+
+		for _, r := range m.Rules {
+			e, ok, err := r.Match(modulePath)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+
+			if _, found := resolved.Modules[e.OldModule]; !found {
+				fmt.Fprintf(w, "Rule: %s -> %s (line %d: %s -> %s)\n", e.OldModule, e.NewModule, r.Line, r.Old, r.New)
+				resolved.add(w, "", e)
+			}
+			break
+		}
+	}
+
+	if m.resolveChains {
+		err := resolved.resolveChains(w, m.matchRule)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// matchRule returns the entry of the first rule that matches the module path.
+func (m *Mapping) matchRule(modulePath string) (Entry, bool, error) {
+	if hostPath, ok := m.vanity.ToHostPath(modulePath); ok {
+		modulePath = hostPath
+	}
+
+	for _, r := range m.Rules {
+		e, ok, err := r.Match(modulePath)
+		if err != nil || ok {
+			return e, ok, err
+		}
+	}
+	return Entry{}, false, nil
+}
+
+// ExpandRepos expands the mapping against the remote urls of the passed repositories
+// as well as against the module paths that are required in all go.mod files of their modules.
+func (m *Mapping) ExpandRepos(ctx context.Context, repoDirs []string, remoteName string) (*Resolved, error) {
+	if len(m.Rules) == 0 {
+		return m.Expand(ctx, nil, nil)
+	}
+
+	gitUrls := make([]string, 0, len(repoDirs))
+	modulePaths := make([]string, 0, len(repoDirs)*8)
+	for _, repoDir := range repoDirs {
+		gitUrl, err := utils.GitRemoteUrl(ctx, repoDir, remoteName)
+		if err == nil {
+			gitUrls = append(gitUrls, gitUrl)
+		}
+
+		moduleDirs, err := utils.FindGoModDirs(repoDir)
+		if err != nil {
+			continue
+		}
+
+		for _, moduleDir := range moduleDirs {
+			data, err := os.ReadFile(filepath.Join(moduleDir, "go.mod"))
+			if err != nil {
+				continue
+			}
+
+			modFile, err := modfile.ParseLax("go.mod", data, nil)
+			if err != nil {
+				continue
+			}
+
+			for _, req := range modFile.Require {
+				modulePaths = append(modulePaths, req.Mod.Path)
+			}
+		}
+	}
+
+	return m.Expand(ctx, gitUrls, modulePaths)
+}
+
+func uniqueSorted(ss []string) []string {
+	m := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		m[s] = true
+	}
+
+	result := make([]string, 0, len(m))
+	for s := range m {
+		result = append(result, s)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ColumnIndexes resolves the old and new column names or indexes of a csv mapping file.
+// Non-csv files always return the passed indexes or 0 and 1 in case they are no indexes.
+func ColumnIndexes(filePath string, commaRune rune, oldColumn, newColumn string) (oldIdx, newIdx int, err error) {
+	oldIdx, errOld := strconv.Atoi(oldColumn)
+	newIdx, errNew := strconv.Atoi(newColumn)
+
+	if errOld == nil && errNew == nil {
+		return oldIdx, newIdx, nil
+	}
+
+	if !IsCSV(filePath) {
+		if errOld != nil {
+			oldIdx = 0
+		}
+		if errNew != nil {
+			newIdx = 1
+		}
+		return oldIdx, newIdx, nil
+	}
+
+	header, err := csv.Header(filePath, commaRune)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for idx, col := range header {
+		if col == oldColumn {
+			oldIdx = idx
+		}
+
+		if col == newColumn {
+			newIdx = idx
+		}
+	}
+	return oldIdx, newIdx, nil
+}
@@ -0,0 +1,240 @@
+package mapping
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandRules(t *testing.T) {
+	r, err := NewRule("ssh://git@git.company.com/project/*", "git@github.com:company/go-*|lower")
+	require.NoError(t, err)
+
+	e, err := NewEntry("ssh://git@git.company.com/project/explicit.git", "git@github.com:other/explicit.git")
+	require.NoError(t, err)
+
+	duplicate, err := NewEntry("git@git.company.com:project/explicit.git", "git@github.com:other/duplicate.git")
+	require.NoError(t, err)
+	duplicate.Line = 2
+
+	m := &Mapping{
+		Entries: []Entry{e, duplicate},
+		Rules:   []Rule{r},
+	}
+
+	var stdout bytes.Buffer
+	ctx := utils.WithOutput(context.Background(), &stdout, &stdout)
+	resolved, err := m.Expand(ctx,
+		[]string{
+			"ssh://git@git.company.com/project/Repo.git",
+			"ssh://git@git.company.com/project/explicit.git",
+			"ssh://git@git.company.com/other/repo.git",
+		},
+		[]string{
+			"git.company.com/project/lib/v2",
+			"git.company.com/project/explicit",
+		},
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{
+		"ssh://git@git.company.com/project/Repo.git":     "ssh://git@github.com/company/go-repo",
+		"ssh://git@git.company.com/project/explicit.git": "ssh://git@github.com/other/explicit.git",
+	}, resolved.GitUrls)
+
+	require.Equal(t, map[string]string{
+		"git.company.com/project/Repo":     "github.com/company/go-repo",
+		"git.company.com/project/lib":      "github.com/company/go-lib",
+		"git.company.com/project/explicit": "github.com/other/explicit",
+	}, resolved.Modules)
+
+	// matched rules and ignored duplicates are written to the output of the context
+	require.Contains(t, stdout.String(), "Identity: line 2: ssh://git@git.company.com/project/explicit.git is the same repository as ssh://git@git.company.com/project/explicit.git")
+	require.Contains(t, stdout.String(), "Rule: ssh://git@git.company.com/project/Repo.git -> ssh://git@github.com/company/go-repo")
+	require.Contains(t, stdout.String(), "Rule: git.company.com/project/lib -> github.com/company/go-lib")
+}
+
+func TestLoadYAML(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "mapping.yaml")
+	err := os.WriteFile(filePath, []byte(`mappings:
+  - old: ssh://git@git.company.com/project/repo.git
+    new: git@github.com:company/repo.git
+    default_branch: main
+    reviewers: [octocat]
+    skip: true
+  - old: ssh://git@git.company.com/other/*.git
+    new: git@github.com:company/*.git
+    labels: [migration]
+`), 0600)
+	require.NoError(t, err)
+
+	m, err := Load(filePath, 0, 1, ';')
+	require.NoError(t, err)
+	require.Len(t, m.Entries, 1)
+	require.Len(t, m.Rules, 1)
+
+	e := m.Entries[0]
+	require.Equal(t, 2, e.Line)
+	require.Equal(t, "git.company.com/project/repo", e.OldModule)
+	require.Equal(t, "github.com/company/repo", e.NewModule)
+	require.Equal(t, "main", e.DefaultBranch)
+	require.Equal(t, []string{"octocat"}, e.Reviewers)
+	require.True(t, e.Skip)
+
+	require.Equal(t, []string{"migration"}, m.Rules[0].Labels)
+}
+
+func TestColumnIndexes(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "mapping.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("id;old;new\n"), 0600))
+
+	oldIdx, newIdx, err := ColumnIndexes(csvPath, ';', "old", "new")
+	require.NoError(t, err)
+	require.Equal(t, 1, oldIdx)
+	require.Equal(t, 2, newIdx)
+
+	// column names of non-csv files fall back to the default indexes
+	oldIdx, newIdx, err = ColumnIndexes(filepath.Join(dir, "mapping.yaml"), ';', "old", "new")
+	require.NoError(t, err)
+	require.Equal(t, 0, oldIdx)
+	require.Equal(t, 1, newIdx)
+
+	oldIdx, newIdx, err = ColumnIndexes(filepath.Join(dir, "mapping.yaml"), ';', "2", "3")
+	require.NoError(t, err)
+	require.Equal(t, 2, oldIdx)
+	require.Equal(t, 3, newIdx)
+}
+
+func TestValidate(t *testing.T) {
+	m := &Mapping{Incomplete: []int{9}}
+	for idx, row := range [][2]string{
+		{"ssh://git@git.company.com/project/a.git", "git@github.com:company/a.git"},
+		{"ssh://git@git.company.com/project/b.git", "git@github.com:company/a.git"},
+		{"ssh://git@git.company.com/project/x.git", "ssh://git@git.company.com/project/y.git"},
+		{"ssh://git@git.company.com/project/y.git", "ssh://git@git.company.com/project/x.git"},
+		{"ssh://git@git.company.com/project/a/sub.git", "git@github.com:company/sub.git"},
+		{"https://git.company.com/scm/project/Y", "git@github.com:company/y.git"},
+	} {
+		require.NoError(t, m.add(idx+2, row[0], row[1], Options{}))
+	}
+
+	kinds := make([]ProblemKind, 0)
+	for _, p := range m.Validate() {
+		kinds = append(kinds, p.Kind)
+	}
+	require.Equal(t, []ProblemKind{ProblemCollision, ProblemCycle, ProblemPrefix, ProblemIdentity, ProblemIncomplete}, kinds)
+}
+
+func TestResolveChains(t *testing.T) {
+	m := &Mapping{}
+	require.NoError(t, m.add(2, "ssh://git@git.company.com/project/a.git", "git@gitlab.company.com:interim/a.git", Options{}))
+	require.NoError(t, m.add(3, "git@gitlab.company.com:interim/a.git", "git@github.com:company/a.git", Options{}))
+
+	var stdout bytes.Buffer
+	require.NoError(t, m.ResolveChains(&stdout))
+	require.Equal(t, "Chain: git.company.com/project/a -> gitlab.company.com/interim/a -> github.com/company/a (lines 2 -> 3)\n", stdout.String())
+	require.Equal(t, "github.com/company/a", m.Entries[0].NewModule)
+	require.Equal(t, "ssh://git@github.com/company/a.git", m.Entries[0].NewUrl)
+	require.Equal(t, []string{"git.company.com/project/a", "gitlab.company.com/interim/a", "github.com/company/a"}, m.Entries[0].Chain)
+	require.Equal(t, "github.com/company/a", m.Entries[1].NewModule)
+
+	require.NoError(t, m.add(4, "ssh://git@github.com/company/a.git", "ssh://git@git.company.com/project/a.git", Options{}))
+	require.Error(t, m.ResolveChains(io.Discard))
+}
+
+func TestExpandResolveChains(t *testing.T) {
+	m := &Mapping{resolveChains: true}
+	require.NoError(t, m.add(2, "ssh://git@git.company.com/project/*", "git@gitlab.company.com:interim/*", Options{}))
+	require.NoError(t, m.add(3, "git@gitlab.company.com:interim/*", "git@github.com:company/*", Options{}))
+	require.NoError(t, m.add(4, "git@gitlab.company.com:interim/special.git", "git@github.com:other/special.git", Options{}))
+
+	var stdout bytes.Buffer
+	ctx := utils.WithOutput(context.Background(), &stdout, &stdout)
+	resolved, err := m.Expand(ctx, []string{
+		"ssh://git@git.company.com/project/a.git",
+		"ssh://git@git.company.com/project/special.git",
+	}, nil)
+	require.NoError(t, err)
+
+	// the targets of expanded rules are mapped again by another rule and by an explicit entry
+	require.Equal(t, map[string]string{
+		"git.company.com/project/a":          "github.com/company/a",
+		"git.company.com/project/special":    "github.com/other/special",
+		"gitlab.company.com/interim/special": "github.com/other/special",
+	}, resolved.Modules)
+	require.Equal(t, "ssh://git@github.com/company/a", resolved.GitUrls["ssh://git@git.company.com/project/a.git"])
+
+	e, found := resolved.Lookup("ssh://git@git.company.com/project/a.git")
+	require.True(t, found)
+	require.Equal(t, []string{"git.company.com/project/a", "gitlab.company.com/interim/a", "github.com/company/a"}, e.Chain)
+	require.Contains(t, stdout.String(), "Chain: git.company.com/project/special -> gitlab.company.com/interim/special -> github.com/other/special (lines 2 -> 4)")
+
+	// rules that map back to the old module path or onto their own targets are cyclic
+	require.NoError(t, m.add(5, "ssh://git@github.com/company/*", "ssh://git@git.company.com/project/*", Options{}))
+	_, err = m.Expand(ctx, []string{"ssh://git@git.company.com/project/a.git"}, nil)
+	require.ErrorContains(t, err, "cyclic mapping")
+
+	m = &Mapping{resolveChains: true}
+	require.NoError(t, m.add(2, "ssh://git@git.company.com/project/*", "ssh://git@git.company.com/project/go-*", Options{}))
+	_, err = m.Expand(ctx, []string{"ssh://git@git.company.com/project/a.git"}, nil)
+	require.ErrorContains(t, err, "cyclic mapping")
+}
+
+func TestDeriveModulePaths(t *testing.T) {
+	m := &Mapping{}
+	require.NoError(t, m.add(2, "ssh://git@git.company.com:7999/project/a.git", "git@github.com:company/a.git", Options{}))
+	require.NoError(t, m.add(3, "https://git.company.com/scm/team/*", "git@github.com:company/*", Options{}))
+
+	rules, err := utils.ParseModulePathRules([]string{"git.company.com=strip-port+strip-prefix:scm"})
+	require.NoError(t, err)
+	require.NoError(t, m.DeriveModulePaths(rules))
+	require.Equal(t, "git.company.com/project/a", m.Entries[0].OldModule)
+
+	resolved, err := m.Expand(context.Background(), []string{"ssh://git@git.company.com:7999/team/x.git"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "github.com/company/x", resolved.Modules["git.company.com/team/x"])
+}
+
+func TestExpandVanity(t *testing.T) {
+	r, err := NewRule("ssh://git@git.company.com/project/*", "git@github.com:company/go-*")
+	require.NoError(t, err)
+
+	e, err := NewEntry("ssh://git@git.company.com/project/explicit.git", "git@github.com:other/explicit.git")
+	require.NoError(t, err)
+	e.Module = "go.other.com/explicit"
+	e.NewModule = e.Module
+
+	vanity, err := ParseVanityTable([]string{"go.company.com=git.company.com/project"})
+	require.NoError(t, err)
+
+	m := &Mapping{
+		Entries: []Entry{e},
+		Rules:   []Rule{r},
+		vanity:  vanity,
+	}
+
+	// the rules match the git host paths of vanity requirements
+	resolved, err := m.Expand(context.Background(), nil, []string{"go.company.com/lib/v2", "go.company.com/explicit", "go.other.com/unknown"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"git.company.com/project/lib":      "github.com/company/go-lib",
+		"git.company.com/project/explicit": "go.other.com/explicit",
+	}, resolved.Modules)
+
+	require.Equal(t, map[string]string{
+		"go.company.com/lib":      "github.com/company/go-lib",
+		"go.company.com/explicit": "go.other.com/explicit",
+	}, resolved.VanityModules(vanity, true))
+
+	// only explicitly requested module paths change declared vanity import paths
+	require.Equal(t, map[string]string{
+		"go.company.com/explicit": "go.other.com/explicit",
+	}, resolved.VanityModules(vanity, false))
+}
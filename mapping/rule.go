@@ -0,0 +1,184 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jxsl13/module-migration/utils"
+)
+
+const (
+	// Wildcard matches exactly one repository name in the last path segment of an old url.
+	Wildcard = "*"
+
+	// TransformSeparator separates the new url template from its name transformations,
+	// e.g. git@github.com:company/go-*|lower
+	TransformSeparator = "|"
+
+	// placeholder that survives git url parsing
+	wildcardPlaceholder = "mmwildcardmm"
+)
+
+// IsRule returns true in case the old url of a mapping row is a wildcard pattern.
+func IsRule(oldUrl string) bool {
+	return strings.Contains(oldUrl, Wildcard)
+}
+
+// Rule maps every repository that matches the old url pattern to the new url template.
+type Rule struct {
+	// Line is the line number of the rule in the mapping file
+	Line int
+
+	Old        string
+	New        string
+	Transforms []Transform
+
+	// Options are applied to every repository that matches the rule
+	Options
+
+	template    string
+	prefix      string // module path prefix
+	suffix      string // module path suffix of the last path segment
+	modulePaths utils.ModulePathRules
+}
+
+// NewRule creates a rule from an old url pattern, e.g. ssh://git@git.company.com/project/*,
+// and a new url template with optional name transformations, e.g. git@github.com:company/*|lower
+func NewRule(oldPattern, newTemplate string) (Rule, error) {
+	if strings.Count(oldPattern, Wildcard) != 1 {
+		return Rule{}, fmt.Errorf("old url pattern must contain exactly one %q: %s", Wildcard, oldPattern)
+	}
+
+	parts := strings.Split(newTemplate, TransformSeparator)
+	template := strings.TrimSpace(parts[0])
+	if strings.Count(template, Wildcard) != 1 {
+		return Rule{}, fmt.Errorf("new url template must contain exactly one %q: %s", Wildcard, newTemplate)
+	}
+
+	transforms := make([]Transform, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		t, err := ParseTransform(strings.TrimSpace(p))
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid new url template: %s: %w", newTemplate, err)
+		}
+		transforms = append(transforms, t)
+	}
+
+	r := Rule{
+		Old:        oldPattern,
+		New:        newTemplate,
+		Transforms: transforms,
+		template:   template,
+	}
+	err := r.derive(nil)
+	if err != nil {
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+// derive computes the module path prefix and suffix of the old url pattern with the module path rules
+// and validates the template.
+func (r *Rule) derive(rules utils.ModulePathRules) error {
+	modulePattern, err := rules.ToModuleUrl(strings.Replace(r.Old, Wildcard, wildcardPlaceholder, 1))
+	if err != nil {
+		return fmt.Errorf("invalid old url pattern: %s: %w", r.Old, err)
+	}
+
+	prefix, suffix, _ := strings.Cut(modulePattern, wildcardPlaceholder)
+	if strings.Contains(suffix, "/") {
+		return fmt.Errorf("wildcard %q must be part of the last path segment: %s", Wildcard, r.Old)
+	}
+
+	r.prefix = prefix
+	r.suffix = suffix
+	r.modulePaths = rules
+
+	// validate the template
+	_, err = newEntry(rules, strings.Replace(r.Old, Wildcard, "name", 1), r.Apply("name"))
+	return err
+}
+
+// Match checks whether the module path or one of its parent paths matches the rule
+// and returns the expanded entry. The expanded entry has no old git url in case it
+// was derived from a module path.
+func (r *Rule) Match(modulePath string) (e Entry, ok bool, err error) {
+	if !strings.HasPrefix(modulePath, r.prefix) {
+		return Entry{}, false, nil
+	}
+
+	segment, _, _ := strings.Cut(modulePath[len(r.prefix):], "/")
+	name := strings.TrimSuffix(segment, r.suffix)
+	if name == "" || len(name) == len(segment) && r.suffix != "" {
+		return Entry{}, false, nil
+	}
+
+	oldModule := r.prefix + segment
+	e, err = newEntry(r.modulePaths, oldModule, r.Apply(name))
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("failed to expand rule %s -> %s for %s: %w", r.Old, r.New, modulePath, err)
+	}
+	e.Line = r.Line
+	e.OldUrl = ""
+	e.Options = r.Options
+	return e, true, nil
+}
+
+// Apply returns the new url for the repository name.
+func (r *Rule) Apply(name string) string {
+	for _, t := range r.Transforms {
+		name = t.Apply(name)
+	}
+	return strings.Replace(r.template, Wildcard, name, 1)
+}
+
+// Transform is a name transformation that is applied to the matched repository name.
+type Transform struct {
+	Name  string
+	Value string
+}
+
+// ParseTransform parses one of lower, upper, prefix=<value>, suffix=<value>,
+// trim-prefix=<value> or trim-suffix=<value>
+func ParseTransform(s string) (Transform, error) {
+	name, value, hasValue := strings.Cut(s, "=")
+	t := Transform{
+		Name:  name,
+		Value: value,
+	}
+
+	switch name {
+	case "lower", "upper":
+		if hasValue {
+			return Transform{}, fmt.Errorf("transform %s does not accept a value", name)
+		}
+	case "prefix", "suffix", "trim-prefix", "trim-suffix":
+		if value == "" {
+			return Transform{}, fmt.Errorf("transform %s requires a value", name)
+		}
+	case "":
+		return Transform{}, errors.New("empty transform")
+	default:
+		return Transform{}, fmt.Errorf("unknown transform: %s", name)
+	}
+	return t, nil
+}
+
+func (t Transform) Apply(name string) string {
+	switch t.Name {
+	case "lower":
+		return strings.ToLower(name)
+	case "upper":
+		return strings.ToUpper(name)
+	case "prefix":
+		return t.Value + name
+	case "suffix":
+		return name + t.Value
+	case "trim-prefix":
+		return strings.TrimPrefix(name, t.Value)
+	case "trim-suffix":
+		return strings.TrimSuffix(name, t.Value)
+	}
+	return name
+}
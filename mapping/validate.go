@@ -0,0 +1,172 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// ProblemKind categorizes problems found by Validate
+type ProblemKind string
+
+const (
+	ProblemIncomplete ProblemKind = "incomplete"
+	ProblemDuplicate  ProblemKind = "duplicate"
+	ProblemCollision  ProblemKind = "collision"
+	ProblemChain      ProblemKind = "chain"
+	ProblemCycle      ProblemKind = "cycle"
+	ProblemModulePath ProblemKind = "module-path"
+	ProblemPrefix     ProblemKind = "prefix"
+	ProblemIdentity   ProblemKind = "identity"
+)
+
+const problemKindPadding = len(ProblemModulePath)
+
+// Problem is a single issue of a mapping file
+type Problem struct {
+	Line    int
+	Kind    ProblemKind
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d: %-*s %s", p.Line, problemKindPadding, p.Kind, p.Message)
+}
+
+// Validate checks the explicit entries of the mapping for incomplete rows,
+// duplicate sources, different url forms of the same source repository, multiple sources that are mapped to the same target,
+// chains and cycles, invalid module paths and old module paths that are prefixes
+// of other old module paths. The problems are sorted by line.
+func (m *Mapping) Validate() []Problem {
+	problems := make([]Problem, 0)
+	add := func(line int, kind ProblemKind, format string, args ...any) {
+		problems = append(problems, Problem{
+			Line:    line,
+			Kind:    kind,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, line := range m.Incomplete {
+		add(line, ProblemIncomplete, "row is ignored because the old or the new url is empty")
+	}
+
+	bySource := make(map[string]Entry, len(m.Entries))
+	byTarget := make(map[string]Entry, len(m.Entries))
+	byIdentity := make(map[string]Entry, len(m.Entries))
+	for _, e := range m.Entries {
+		id := identity(e.OldUrl)
+		if prev, found := byIdentity[id]; !found {
+			byIdentity[id] = e
+		} else if prev.OldModule != e.OldModule {
+			// same module paths are reported as duplicates
+			add(e.Line, ProblemIdentity, "%s and %s (line %d) are the same repository %s", e.OldUrl, prev.OldUrl, prev.Line, id)
+		}
+
+		if err := module.CheckPath(e.OldModule); err != nil {
+			add(e.Line, ProblemModulePath, "invalid old module path: %v", err)
+		}
+		if err := module.CheckPath(e.NewModule); err != nil {
+			add(e.Line, ProblemModulePath, "invalid new module path: %v", err)
+		}
+
+		if prev, found := bySource[e.OldModule]; found {
+			if prev.NewModule == e.NewModule {
+				add(e.Line, ProblemDuplicate, "%s is already mapped in line %d", e.OldModule, prev.Line)
+			} else {
+				add(e.Line, ProblemDuplicate, "%s -> %s conflicts with line %d: %s -> %s", e.OldModule, e.NewModule, prev.Line, prev.OldModule, prev.NewModule)
+			}
+		} else {
+			bySource[e.OldModule] = e
+		}
+
+		if prev, found := byTarget[e.NewModule]; found && prev.OldModule != e.OldModule {
+			add(e.Line, ProblemCollision, "%s and %s (line %d) are both mapped to %s", e.OldModule, prev.OldModule, prev.Line, e.NewModule)
+		} else if !found {
+			byTarget[e.NewModule] = e
+		}
+	}
+
+	reportedCycles := make(map[string]bool)
+	for _, e := range m.Entries {
+		if e.OldModule == e.NewModule {
+			continue
+		}
+
+		next, found := bySource[e.NewModule]
+		if !found {
+			continue
+		}
+
+		cycle, isCycle := findCycle(bySource, e)
+		if !isCycle {
+			add(e.Line, ProblemChain, "%s -> %s is mapped again in line %d: %s -> %s", e.OldModule, e.NewModule, next.Line, next.OldModule, next.NewModule)
+			continue
+		}
+
+		key := cycleKey(cycle)
+		if reportedCycles[key] {
+			continue
+		}
+		reportedCycles[key] = true
+		add(e.Line, ProblemCycle, "%s", strings.Join(append(cycle, cycle[0]), " -> "))
+	}
+
+	oldModules := sortedKeys(bySource)
+	for _, prefix := range oldModules {
+		for _, other := range oldModules {
+			if strings.HasPrefix(other, prefix+"/") {
+				add(bySource[other].Line, ProblemPrefix, "%s is a sub path of %s (line %d)", other, prefix, bySource[prefix].Line)
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
+
+// findCycle follows the chain starting at e and returns the visited module paths
+// in case the chain leads back to the start.
+func findCycle(bySource map[string]Entry, e Entry) ([]string, bool) {
+	visited := make(map[string]bool)
+	path := []string{e.OldModule}
+	visited[e.OldModule] = true
+
+	current := e
+	for {
+		next, found := bySource[current.NewModule]
+		if !found || next.OldModule == next.NewModule {
+			return nil, false
+		}
+		if next.OldModule == e.OldModule {
+			return path, true
+		}
+		if visited[next.OldModule] {
+			// cycle that does not contain e
+			return nil, false
+		}
+		visited[next.OldModule] = true
+		path = append(path, next.OldModule)
+		current = next
+	}
+}
+
+func cycleKey(cycle []string) string {
+	sorted := append([]string(nil), cycle...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\n")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,89 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VanityTable maps vanity import path prefixes, e.g. go.company.com,
+// to git host path prefixes, e.g. git.company.com/project
+type VanityTable map[string]string
+
+// ParseVanityTable parses a list of vanity=host entries.
+func ParseVanityTable(entries []string) (VanityTable, error) {
+	t := make(VanityTable, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		vanity, host, found := strings.Cut(entry, "=")
+		vanity = strings.Trim(strings.TrimSpace(vanity), "/")
+		host = strings.Trim(strings.TrimSpace(host), "/")
+		if !found || vanity == "" || host == "" {
+			return nil, fmt.Errorf("invalid vanity mapping, expected <vanity prefix>=<git host prefix>: %s", entry)
+		}
+		t[vanity] = host
+	}
+	return t, nil
+}
+
+// ToHostPath translates a vanity module path into the module path derived from its git url.
+func (t VanityTable) ToHostPath(modulePath string) (string, bool) {
+	vanity, ok := longestPrefix(t, modulePath)
+	if !ok {
+		return modulePath, false
+	}
+	return t[vanity] + modulePath[len(vanity):], true
+}
+
+// ToVanityPath translates a module path derived from a git url into its vanity module path.
+func (t VanityTable) ToVanityPath(hostPath string) (string, bool) {
+	reverse := make(map[string]string, len(t))
+	for vanity, host := range t {
+		reverse[host] = vanity
+	}
+
+	host, ok := longestPrefix(reverse, hostPath)
+	if !ok {
+		return hostPath, false
+	}
+	return reverse[host] + hostPath[len(host):], true
+}
+
+func longestPrefix(m map[string]string, path string) (string, bool) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	// longest prefix first
+	sort.Slice(keys, func(i, j int) bool {
+		return len(keys[i]) > len(keys[j])
+	})
+
+	for _, k := range keys {
+		if path == k || strings.HasPrefix(path, k+"/") {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// VanityModules returns the mapping of vanity module paths to the new module paths of the resolved modules.
+// Unless all is set, only entries that explicitly request a new module path are returned and
+// the vanity module paths of all other entries are kept as they are.
+func (r *Resolved) VanityModules(t VanityTable, all bool) map[string]string {
+	result := make(map[string]string)
+	for oldModule, e := range r.byModule {
+		if e.Module == "" && !all {
+			continue
+		}
+
+		vanity, ok := t.ToVanityPath(oldModule)
+		if ok {
+			result[vanity] = e.NewModule
+		}
+	}
+	return result
+}
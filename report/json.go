@@ -0,0 +1,12 @@
+package report
+
+import "encoding/json"
+
+// JSON returns the report as indented json.
+func (r *Report) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
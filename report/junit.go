@@ -0,0 +1,109 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/jxsl13/module-migration/fleet"
+)
+
+// junitTestSuites is the root element of a JUnit xml report.
+// Every repository is a test case, so CI servers show the result per repository.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      Seconds         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      Seconds       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit returns the report as JUnit xml.
+func (r *Report) JUnit() ([]byte, error) {
+	suite := junitTestSuite{
+		Name:      "module-migration " + r.Command,
+		Tests:     len(r.Repositories),
+		Failures:  r.Failed,
+		Skipped:   r.Skipped,
+		Time:      r.Duration,
+		Timestamp: r.Started.Format("2006-01-02T15:04:05"),
+		Cases:     make([]junitTestCase, 0, len(r.Repositories)),
+	}
+
+	for _, repo := range r.Repositories {
+		tc := junitTestCase{
+			ClassName: r.Command,
+			Name:      repo.Path,
+			Time:      repo.Duration,
+			SystemOut: repo.details(),
+		}
+		switch repo.Status {
+		case string(fleet.StatusFailed):
+			tc.Failure = &junitMessage{Message: repo.Reason, Text: repo.failedStepDetails()}
+		case string(fleet.StatusSkipped):
+			tc.Skipped = &junitMessage{Message: repo.Reason}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// details lists all steps and touched files of the repository.
+func (repo *Repository) details() string {
+	var sb strings.Builder
+	for _, s := range repo.Steps {
+		status := "ok"
+		if s.Error != "" {
+			status = "failed"
+		}
+		fmt.Fprintf(&sb, "step %s: %s (%.3fs)\n", s.Name, status, s.Duration)
+	}
+	for _, f := range repo.Files {
+		fmt.Fprintf(&sb, "file %s\n", f)
+	}
+	return sb.String()
+}
+
+// failedStepDetails returns the error and the command output of all failed steps.
+func (repo *Repository) failedStepDetails() string {
+	var sb strings.Builder
+	for _, s := range repo.Steps {
+		if s.Error == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "step %s failed: %s\n", s.Name, s.Error)
+		if s.Exec != nil {
+			fmt.Fprintf(&sb, "command: %s %s\nexit code: %d\n", s.Exec.Cmd, strings.Join(s.Exec.Args, " "), s.Exec.ExitCode)
+			if s.Exec.Output != "" {
+				fmt.Fprintf(&sb, "output:\n%s\n", s.Exec.Output)
+			}
+		}
+	}
+	return sb.String()
+}
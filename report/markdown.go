@@ -0,0 +1,45 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jxsl13/module-migration/fleet"
+)
+
+// Markdown returns the report as Markdown that can be pasted into issues.
+func (r *Report) Markdown() []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# module-migration %s\n\n", r.Command)
+	fmt.Fprintf(&sb, "Started %s in `%s`, took %.1fs: %d succeeded, %d failed, %d skipped\n\n",
+		r.Started.Format("2006-01-02 15:04:05"), r.RootPath, r.Duration, r.Succeeded, r.Failed, r.Skipped)
+
+	sb.WriteString("| Repository | Status | Duration | Files | Reason |\n")
+	sb.WriteString("|---|---|---|---|---|\n")
+	for _, repo := range r.Repositories {
+		fmt.Fprintf(&sb, "| `%s` | %s | %.1fs | %d | %s |\n",
+			repo.Path, repo.Status, repo.Duration, len(repo.Files), markdownCell(repo.Reason))
+	}
+
+	for _, repo := range r.Repositories {
+		if repo.Status != string(fleet.StatusFailed) || len(repo.Steps) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## `%s`\n\n", repo.Path)
+		for _, s := range repo.Steps {
+			status := "ok"
+			if s.Error != "" {
+				status = "failed"
+			}
+			fmt.Fprintf(&sb, "- %s: %s (%.1fs)\n", s.Name, status, s.Duration)
+		}
+		if details := repo.failedStepDetails(); details != "" {
+			fmt.Fprintf(&sb, "\n```\n%s```\n", details)
+		}
+	}
+	return []byte(sb.String())
+}
+
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
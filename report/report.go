@@ -0,0 +1,154 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/utils"
+)
+
+// Report is the machine readable artifact of a single run of a fleet command.
+type Report struct {
+	Command      string       `json:"command"`
+	RootPath     string       `json:"root_path"`
+	Started      time.Time    `json:"started"`
+	Duration     Seconds      `json:"duration_seconds"`
+	Succeeded    int          `json:"succeeded"`
+	Failed       int          `json:"failed"`
+	Skipped      int          `json:"skipped"`
+	Repositories []Repository `json:"repositories"`
+}
+
+// Repository is the result of a single repository.
+type Repository struct {
+	Path     string   `json:"path"`
+	Status   string   `json:"status"`
+	Reason   string   `json:"reason,omitempty"`
+	Duration Seconds  `json:"duration_seconds"`
+	Steps    []Step   `json:"steps,omitempty"`
+	Files    []string `json:"files,omitempty"`
+}
+
+// Step is a single step that was executed for a repository.
+type Step struct {
+	Name     string  `json:"name"`
+	Duration Seconds `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+	Exec     *Exec   `json:"exec,omitempty"`
+}
+
+// Exec contains the details of a failed external command.
+type Exec struct {
+	Cmd         string   `json:"cmd"`
+	Args        []string `json:"args"`
+	ExitCode    int      `json:"exit_code"`
+	SubExitCode int      `json:"sub_exit_code,omitempty"`
+	Output      string   `json:"output,omitempty"`
+	ErrOutput   string   `json:"error_output,omitempty"`
+}
+
+// Seconds is a duration that is serialized as floating point seconds.
+type Seconds float64
+
+func toSeconds(d time.Duration) Seconds {
+	return Seconds(d.Round(time.Millisecond).Seconds())
+}
+
+// New creates a report of the results of a fleet command.
+// Repository paths are relative to rootPath.
+func New(command, rootPath string, started time.Time, results fleet.Results) *Report {
+	r := &Report{
+		Command:      command,
+		RootPath:     rootPath,
+		Started:      started,
+		Duration:     toSeconds(time.Since(started)),
+		Succeeded:    results.Count(fleet.StatusSucceeded),
+		Failed:       results.Count(fleet.StatusFailed),
+		Skipped:      results.Count(fleet.StatusSkipped),
+		Repositories: make([]Repository, 0, len(results)),
+	}
+
+	for _, result := range results {
+		path, err := filepath.Rel(rootPath, result.RepoDir)
+		if err != nil {
+			path = result.RepoDir
+		}
+
+		repo := Repository{
+			Path:     filepath.ToSlash(path),
+			Status:   string(result.Status()),
+			Reason:   result.Reason(),
+			Duration: toSeconds(result.Duration),
+			Steps:    make([]Step, 0, len(result.Steps)),
+			Files:    result.Files,
+		}
+		for _, s := range result.Steps {
+			repo.Steps = append(repo.Steps, newStep(s))
+		}
+		r.Repositories = append(r.Repositories, repo)
+	}
+	return r
+}
+
+func newStep(s fleet.Step) Step {
+	step := Step{
+		Name:     s.Name,
+		Duration: toSeconds(s.Duration),
+	}
+	if s.Err == nil {
+		return step
+	}
+	step.Error = s.Err.Error()
+
+	var e utils.ErrExec
+	if errors.As(s.Err, &e) {
+		step.Exec = &Exec{
+			Cmd:         e.Cmd,
+			Args:        e.Args,
+			ExitCode:    e.ExitCode,
+			SubExitCode: e.SubExitCode,
+			Output:      e.Output,
+			ErrOutput:   e.ErrOutput,
+		}
+	}
+	return step
+}
+
+// CheckPath returns an error in case the report format cannot be derived from the file extension.
+func CheckPath(filePath string) error {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json", ".xml", ".md":
+		return nil
+	default:
+		return fmt.Errorf("unsupported report file extension, expected one of .json, .xml (JUnit) or .md (Markdown): %s", filePath)
+	}
+}
+
+// WriteFile writes the report. The format is selected by the file extension:
+// .json for json, .xml for JUnit xml and .md for Markdown.
+func (r *Report) WriteFile(filePath string) error {
+	err := CheckPath(filePath)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		data, err = r.JSON()
+	case ".xml":
+		data, err = r.JUnit()
+	case ".md":
+		data = r.Markdown()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create report %s: %w", filePath, err)
+	}
+
+	return os.WriteFile(filePath, data, 0666)
+}
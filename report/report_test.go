@@ -0,0 +1,60 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jxsl13/module-migration/fleet"
+	"github.com/jxsl13/module-migration/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	execErr := utils.ErrExec{ExitCode: 1, Output: "build failed", Cmd: "go", Args: []string{"build", "./..."}}
+	results := fleet.Results{
+		{
+			RepoDir:  "/root/a",
+			Duration: time.Second,
+			Steps:    []fleet.Step{{Name: "tidy"}},
+			Files:    []string{"go.mod", "main.go"},
+		},
+		{
+			RepoDir: "/root/b",
+			Err:     fmt.Errorf("failed: %w", execErr),
+			Steps:   []fleet.Step{{Name: "tidy"}, {Name: "build", Err: execErr}},
+		},
+		{
+			RepoDir: "/root/c",
+			Err:     fleet.ErrSkipped,
+		},
+	}
+
+	r := New("migrate", "/root", time.Now(), results)
+	require.Equal(t, 1, r.Succeeded)
+	require.Equal(t, 1, r.Failed)
+	require.Equal(t, 1, r.Skipped)
+	require.Equal(t, "b", r.Repositories[1].Path)
+	require.NotNil(t, r.Repositories[1].Steps[1].Exec)
+	require.Equal(t, 1, r.Repositories[1].Steps[1].Exec.ExitCode)
+
+	data, err := r.JSON()
+	require.NoError(t, err)
+	var decoded Report
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, []string{"go.mod", "main.go"}, decoded.Repositories[0].Files)
+
+	junit, err := r.JUnit()
+	require.NoError(t, err)
+	require.Contains(t, string(junit), `tests="3" failures="1" skipped="1"`)
+	require.Contains(t, string(junit), "build failed")
+
+	md := string(r.Markdown())
+	require.Contains(t, md, "| `b` | failed |")
+	require.Equal(t, 1, strings.Count(md, "\n## "))
+
+	require.Error(t, CheckPath("report.txt"))
+	require.NoError(t, CheckPath("report.XML"))
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	diff := UnifiedDiff("a/f", "b/f", []byte("a\nb\nc\n"), []byte("a\nx\nc\n"))
+	require.Equal(t, "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n", diff)
+	require.Empty(t, UnifiedDiff("a/f", "b/f", []byte("a\n"), []byte("a\n")))
+}
+
+func TestUnifiedDiffNoNewline(t *testing.T) {
+	diff := UnifiedDiff("a/f", "b/f", []byte("a\nb"), []byte("a\nc"))
+	require.Equal(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n", diff)
+
+	// only the line break at the end changed
+	diff = UnifiedDiff("a/f", "b/f", []byte("a\nb"), []byte("a\nb\n"))
+	require.Equal(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n", diff)
+}
+
+func TestUnifiedDiffLarge(t *testing.T) {
+	var oldText, newText strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&oldText, "old %d\n", i)
+		fmt.Fprintf(&newText, "new %d\n", i)
+	}
+
+	// more edits than maxDiffEdits replace all lines
+	diff := UnifiedDiff("a/f", "b/f", []byte("same\n"+oldText.String()), []byte("same\n"+newText.String()))
+	require.True(t, strings.HasPrefix(diff, "--- a/f\n+++ b/f\n@@ -1,5001 +1,5001 @@\n same\n-old 0\n-old 1\n"), diff[:100])
+	require.Equal(t, 5000, strings.Count(diff, "\n-old "))
+	require.Equal(t, 5000, strings.Count(diff, "\n+new "))
+}
+
+func TestDiffLines(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rnd.Intn(50))
+		for i := range lines {
+			lines[i] = fmt.Sprintf("%d\n", rnd.Intn(5))
+		}
+		return lines
+	}
+
+	for i := 0; i < 200; i++ {
+		a, b := random(), random()
+		var oldLines, newLines []string
+		for _, op := range diffLines(a, b) {
+			if op.kind != '+' {
+				oldLines = append(oldLines, op.line)
+			}
+			if op.kind != '-' {
+				newLines = append(newLines, op.line)
+			}
+		}
+		require.Equal(t, strings.Join(a, ""), strings.Join(oldLines, ""))
+		require.Equal(t, strings.Join(b, ""), strings.Join(newLines, ""))
+	}
+}
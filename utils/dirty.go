@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DirtyAbort refuses to process repositories with local changes
+	DirtyAbort = "abort"
+	// DirtyStash stashes local changes and restores them after the migration commit
+	DirtyStash = "stash"
+
+	// LocalChangesMessage is the stash message of local changes that are restored after the migration commit
+	LocalChangesMessage = "module-migration: local changes"
+)
+
+// CheckDirtyMode returns an error in case mode is neither DirtyAbort nor DirtyStash.
+func CheckDirtyMode(mode string) error {
+	switch mode {
+	case DirtyAbort, DirtyStash:
+		return nil
+	default:
+		return fmt.Errorf("invalid dirty working tree mode %q, expected one of %q or %q", mode, DirtyAbort, DirtyStash)
+	}
+}
+
+// ErrDirty is returned for repositories with uncommitted or untracked changes.
+type ErrDirty struct {
+	RepoDir string
+	Files   []string
+}
+
+func (e ErrDirty) Error() string {
+	const maxFiles = 5
+	files := e.Files
+	if len(files) > maxFiles {
+		files = append(files[:maxFiles:maxFiles], "...")
+	}
+	return fmt.Sprintf("working tree of %s has %d uncommitted or untracked files (%s): commit or stash them or use --dirty=%s",
+		e.RepoDir,
+		len(e.Files),
+		strings.Join(files, ", "),
+		DirtyStash,
+	)
+}
+
+// HandleLocalChanges either returns ErrDirty or stashes the passed files with the LocalChangesMessage,
+// depending on mode. Nothing is done in case there are no files.
+func HandleLocalChanges(ctx context.Context, repoDir, mode string, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	if mode != DirtyStash {
+		return ErrDirty{RepoDir: repoDir, Files: files}
+	}
+
+	fmt.Fprintf(Stdout(ctx), "Local changes: stashing %d files in %s\n", len(files), repoDir)
+	return GitStashPush(ctx, repoDir, LocalChangesMessage, files...)
+}
+
+// HasStashedLocalChanges returns true in case there are stashes that were created by HandleLocalChanges.
+func HasStashedLocalChanges(ctx context.Context, repoDir string) (bool, error) {
+	ref, _, err := findLocalChanges(ctx, repoDir)
+	return ref != "", err
+}
+
+// findLocalChanges returns the newest stash of local changes and the branch it was created on.
+// The branch is empty in case the stash was created on a detached HEAD.
+func findLocalChanges(ctx context.Context, repoDir string) (ref, branch string, err error) {
+	refs, subjects, err := GitStashList(ctx, repoDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for idx, subject := range subjects {
+		// On <branch>: <message>
+		prefix, found := strings.CutSuffix(subject, ": "+LocalChangesMessage)
+		if !found {
+			continue
+		}
+		branch = strings.TrimPrefix(prefix, "On ")
+		if branch == "(no branch)" {
+			branch = ""
+		}
+		return refs[idx], branch, nil
+	}
+	return "", "", nil
+}
+
+// RestoreLocalChanges restores all stashes that were created by HandleLocalChanges, newest first,
+// on the branch they were created on. Stashes that cannot be restored are kept and reported.
+func RestoreLocalChanges(ctx context.Context, repoDir string) error {
+	for {
+		ref, branch, err := findLocalChanges(ctx, repoDir)
+		if err != nil || ref == "" {
+			return err
+		}
+
+		if branch != "" {
+			current, err := GitGetBranchName(ctx, repoDir)
+			if err != nil {
+				return err
+			}
+			if current != branch {
+				err = GitCheckoutBranch(ctx, repoDir, branch)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		fmt.Fprintf(Stdout(ctx), "Local changes: restoring %s in %s\n", ref, repoDir)
+		err = GitStashPop(ctx, repoDir, ref)
+		if err != nil {
+			return errors.Join(err, fmt.Errorf("local changes are kept in %s of %s", ref, repoDir))
+		}
+	}
+}
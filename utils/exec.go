@@ -60,7 +60,7 @@ func ExecuteQuietPathApplicationWithOutput(ctx context.Context, workingDir, cmd
 
 	c.Stderr = io.MultiWriter(combinedOut, stderrBuf)
 	c.Stdout = combinedOut
-	fmt.Printf("Executing: %s\n", c.String())
+	fmt.Fprintf(Stdout(ctx), "Executing: %s\n", c.String())
 	err = c.Run()
 	if err != nil {
 
@@ -61,3 +61,49 @@ func Copy(ctx context.Context, src, targetDir string) error {
 	}
 	return nil
 }
+
+// ComputeCopy returns the file changes that Copy would apply without modifying any files.
+func ComputeCopy(src, targetDir string) ([]FileChange, error) {
+	src = filepath.Clean(src)
+	targetDir = filepath.Join(filepath.Clean(targetDir), filepath.Base(src))
+
+	changes := make([]FileChange, 0, 8)
+	err := filepath.Walk(src, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		after, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(targetDir, rel)
+		before, err := os.ReadFile(target)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		c := FileChange{
+			Path:   target,
+			Before: before,
+			After:  after,
+		}
+		if c.Changed() {
+			changes = append(changes, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute copy of %s into %s: %w", src, targetDir, err)
+	}
+	return changes, nil
+}
@@ -3,18 +3,81 @@ package utils
 import (
 	"context"
 	"fmt"
+	"strings"
 )
 
 var isGhAvailable = IsApplicationAvailable(context.Background(), "gh")
 
-func CreateGithubPullRequest(ctx context.Context, repoDir string, title string) error {
+// PullRequest contains the optional settings of a new pull request.
+type PullRequest struct {
+	Title string
+	Body  string
+
+	// Base is the target branch of the pull request, the default branch if empty
+	Base      string
+	Reviewers []string
+	Labels    []string
+}
+
+func CreateGithubPullRequest(ctx context.Context, repoDir string, pr PullRequest) error {
 	if !isGhAvailable {
 		return nil
 	}
 
-	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "gh", "pr", "create", "--title", title, "--body", title)
+	body := pr.Body
+	if body == "" {
+		body = pr.Title
+	}
+
+	args := []string{"pr", "create", "--title", pr.Title, "--body", body}
+	if pr.Base != "" {
+		args = append(args, "--base", pr.Base)
+	}
+	if len(pr.Reviewers) > 0 {
+		args = append(args, "--reviewer", strings.Join(pr.Reviewers, ","))
+	}
+	if len(pr.Labels) > 0 {
+		args = append(args, "--label", strings.Join(pr.Labels, ","))
+	}
+
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "gh", args...)
 	if err != nil {
 		return fmt.Errorf("failed to create  Github pull request in %s: %w", repoDir, err)
 	}
 	return nil
 }
+
+// UpdateGithubPullRequest updates the title, body, reviewers and labels of the open pull request
+// of the branch. A new pull request is created in case the branch has no open pull request.
+func UpdateGithubPullRequest(ctx context.Context, repoDir, branch string, pr PullRequest) error {
+	if !isGhAvailable {
+		return nil
+	}
+
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "gh", "pr", "view", branch, "--json", "state", "--jq", ".state")
+	if err != nil || strings.TrimSpace(strings.Join(lines, "")) != "OPEN" {
+		return CreateGithubPullRequest(ctx, repoDir, pr)
+	}
+
+	body := pr.Body
+	if body == "" {
+		body = pr.Title
+	}
+
+	args := []string{"pr", "edit", branch, "--title", pr.Title, "--body", body}
+	if pr.Base != "" {
+		args = append(args, "--base", pr.Base)
+	}
+	if len(pr.Reviewers) > 0 {
+		args = append(args, "--add-reviewer", strings.Join(pr.Reviewers, ","))
+	}
+	if len(pr.Labels) > 0 {
+		args = append(args, "--add-label", strings.Join(pr.Labels, ","))
+	}
+
+	_, err = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "gh", args...)
+	if err != nil {
+		return fmt.Errorf("failed to update Github pull request of %s in %s: %w", branch, repoDir, err)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
@@ -16,6 +17,13 @@ import (
 
 var gitDirMatcher = regexp.MustCompile(Separator + `\.git$`)
 
+// ErrShallowUpdate is returned by GitPushUpstream in case the remote rejected the commits of a shallow clone,
+// because it does not contain their history.
+var ErrShallowUpdate = errors.New("shallow update not allowed")
+
+// ErrUnpushedCommits is returned by GitCheckBranchPushed in case the local branch has commits that would be lost.
+var ErrUnpushedCommits = errors.New("unpushed commits")
+
 func FindGitDirs(rootPath string) ([]string, error) {
 	gitFolders := make(map[string]bool, 512)
 
@@ -50,6 +58,7 @@ func FindRepoDirs(rootPath string) ([]string, error) {
 }
 
 // FindGoRepoDirs returns the parent directories of all found Go repo directories which are also git directories.
+// A Go repository contains at least one go.mod file, which is not necessarily located in its root directory.
 func FindGoRepoDirs(rootPath string) ([]string, error) {
 	repos, err := FindRepoDirs(rootPath)
 	if err != nil {
@@ -58,16 +67,12 @@ func FindGoRepoDirs(rootPath string) ([]string, error) {
 
 	goRepos := make([]string, 0, len(repos))
 	for _, repo := range repos {
-		fi, found, err := Exists(filepath.Join(repo, "go.mod"))
+		moduleDirs, err := FindGoModDirs(repo)
 		if err != nil {
 			return nil, err
 		}
 
-		if !found {
-			continue
-		}
-
-		if fi.IsDir() {
+		if len(moduleDirs) == 0 {
 			continue
 		}
 
@@ -111,18 +116,183 @@ func GitExistsBranch(ctx context.Context, repoDir, branchName string) bool {
 	return err == nil
 }
 
+// GitChangeRemoteUrl changes the url of the remote and keeps its fetch refspecs and the upstream tracking of local branches.
 func GitChangeRemoteUrl(ctx context.Context, repoDir, remoteName, targetUrl string) error {
-	// remove remote url
-	_, _ = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "remote", "remove", remoteName)
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "remote", "set-url", remoteName, targetUrl)
+	if err != nil {
+		return fmt.Errorf("failed to change remote url of %s to %s (%s): %w", repoDir, targetUrl, remoteName, err)
+	}
+	return nil
+}
+
+// GitSwitchRemote verifies that the target url is reachable, renames the remote to the legacy name and adds the
+// target url with the name of the remote. The fetch refspecs of the remote and the upstream tracking of local
+// branches are moved to the new remote. The url of the remote is changed in place in case the legacy name is empty.
+// Nothing is changed in case the remote already points to the target url. The original remote, its fetch
+// refspecs and the upstream tracking are restored in case the new remote cannot be fetched.
+func GitSwitchRemote(ctx context.Context, repoDir, remoteName, legacyName, targetUrl string) (err error) {
+	currentUrl, err := GitRemoteUrl(ctx, repoDir, remoteName)
+	if err != nil {
+		return err
+	}
+
+	target, err := giturls.Parse(targetUrl)
+	if err == nil && target.String() == currentUrl {
+		return nil
+	}
+
+	// never touch the remote in case the new url does not work
+	err = GitCheckRemoteUrl(ctx, repoDir, targetUrl)
+	if err != nil {
+		return err
+	}
+
+	if legacyName == "" {
+		return GitChangeRemoteUrl(ctx, repoDir, remoteName, targetUrl)
+	}
+
+	if _, e := GitRemoteUrl(ctx, repoDir, legacyName); e == nil {
+		return fmt.Errorf("legacy remote %s already exists in %s", legacyName, repoDir)
+	}
+
+	refspecs, err := gitConfigValues(ctx, repoDir, fmt.Sprintf("remote.%s.fetch", remoteName))
+	if err != nil {
+		return err
+	}
+
+	tracking, err := gitTrackingBranches(ctx, repoDir, remoteName)
+	if err != nil {
+		return err
+	}
+
+	// removing a remote also removes the upstream tracking of its branches, which must be restored on failure
+	merges := make(map[string][]string, len(tracking))
+	for _, branch := range tracking {
+		merges[branch], err = gitConfigValues(ctx, repoDir, fmt.Sprintf("branch.%s.merge", branch))
+		if err != nil {
+			return err
+		}
+	}
+
+	// git rewrites the refspecs and the upstream tracking to the legacy remote
+	_, err = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "remote", "rename", remoteName, legacyName)
+	if err != nil {
+		return fmt.Errorf("failed to rename remote %s to %s in %s: %w", remoteName, legacyName, repoDir, err)
+	}
+	defer func() {
+		if err != nil {
+			e := gitRestoreRemote(ctx, repoDir, remoteName, legacyName, refspecs, merges)
+			if e != nil {
+				err = errors.Join(err, fmt.Errorf("failed to restore remote %s: %w", remoteName, e))
+			}
+		}
+	}()
+
+	_, err = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "remote", "add", remoteName, targetUrl)
+	if err != nil {
+		return fmt.Errorf("failed to add remote %s with url %s in %s: %w", remoteName, targetUrl, repoDir, err)
+	}
+
+	if len(refspecs) > 0 {
+		err = gitSetConfigValues(ctx, repoDir, fmt.Sprintf("remote.%s.fetch", remoteName), refspecs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, branch := range tracking {
+		_, err = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "config", fmt.Sprintf("branch.%s.remote", branch), remoteName)
+		if err != nil {
+			return fmt.Errorf("failed to restore upstream tracking of %s in %s: %w", branch, repoDir, err)
+		}
+	}
 
-	// add remote url
-	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "remote", "add", remoteName, targetUrl)
+	// the remote is completely configured before its refs are fetched
+	return GitFetch(ctx, repoDir, remoteName)
+}
+
+// gitRestoreRemote removes the new remote, renames the legacy remote back and restores the fetch refspecs
+// of the remote and the upstream tracking of the passed branches.
+func gitRestoreRemote(ctx context.Context, repoDir, remoteName, legacyName string, refspecs []string, merges map[string][]string) error {
+	if _, err := GitRemoteUrl(ctx, repoDir, remoteName); err == nil {
+		_, err = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "remote", "remove", remoteName)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "remote", "rename", legacyName, remoteName)
 	if err != nil {
-		return fmt.Errorf("failed to change remote url of %s to %s (%s)", repoDir, targetUrl, remoteName)
+		return err
+	}
+
+	err = gitSetConfigValues(ctx, repoDir, fmt.Sprintf("remote.%s.fetch", remoteName), refspecs)
+	if err != nil {
+		return err
+	}
+
+	for branch, merge := range merges {
+		err = gitSetConfigValues(ctx, repoDir, fmt.Sprintf("branch.%s.remote", branch), []string{remoteName})
+		if err != nil {
+			return err
+		}
+		err = gitSetConfigValues(ctx, repoDir, fmt.Sprintf("branch.%s.merge", branch), merge)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gitSetConfigValues replaces all values of a multi valued git config key.
+func gitSetConfigValues(ctx context.Context, repoDir, key string, values []string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "config", "--unset-all", key)
+	var e ErrExec
+	if err != nil && !(errors.As(err, &e) && e.ExitCode == 5) {
+		// exit code 5 means the key does not exist
+		return fmt.Errorf("failed to reset git config %s in %s: %w", key, repoDir, err)
+	}
+
+	for _, value := range values {
+		_, err = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "config", "--add", key, value)
+		if err != nil {
+			return fmt.Errorf("failed to add git config %s=%s in %s: %w", key, value, repoDir, err)
+		}
 	}
 	return nil
 }
 
+// gitConfigValues returns all values of a multi valued git config key, which may not exist.
+func gitConfigValues(ctx context.Context, repoDir, key string) ([]string, error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "config", "--get-all", key)
+	if err != nil {
+		var e ErrExec
+		if errors.As(err, &e) && e.ExitCode == 1 {
+			// key does not exist
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read git config %s in %s: %w", key, repoDir, err)
+	}
+	return removeEmptyLines(lines), nil
+}
+
+// gitTrackingBranches returns the local branches whose upstream branch belongs to the remote.
+func gitTrackingBranches(ctx context.Context, repoDir, remoteName string) ([]string, error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "for-each-ref", "--format=%(refname:short) %(upstream:remotename)", "refs/heads")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upstream branches in %s: %w", repoDir, err)
+	}
+
+	branches := make([]string, 0, len(lines))
+	for _, line := range removeEmptyLines(lines) {
+		branch, remote, _ := strings.Cut(line, " ")
+		if remote == remoteName {
+			branches = append(branches, branch)
+		}
+	}
+	return branches, nil
+}
+
 func GitCheckRemoteUrl(ctx context.Context, repoDir, targetUrl string) error {
 	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "ls-remote", targetUrl)
 	if err != nil {
@@ -240,15 +410,268 @@ func GitCommit(ctx context.Context, repoDir string, message string) error {
 
 func GitPushUpstream(ctx context.Context, repoDir string, remoteName, targetBranch string) error {
 	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "push", "--set-upstream", remoteName, targetBranch)
+	var e ErrExec
+	if errors.As(err, &e) && strings.Contains(e.ErrOutput, "shallow update not allowed") {
+		return fmt.Errorf("failed to push to upstream (%s) branch %s in %s: %w: %w", remoteName, targetBranch, repoDir, ErrShallowUpdate, err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to push to upstream (%s) branch %s in %s: %w", remoteName, targetBranch, repoDir, err)
 	}
 	return nil
 }
 
+// GitForcePushWithLease overwrites the remote branch in case it still points to the expected commit hash.
+func GitForcePushWithLease(ctx context.Context, repoDir string, remoteName, targetBranch, expected string) error {
+	lease := fmt.Sprintf("--force-with-lease=%s:%s", targetBranch, expected)
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "push", lease, "--set-upstream", remoteName, targetBranch)
+	if err != nil {
+		return fmt.Errorf("failed to force push to upstream (%s) branch %s in %s: %w", remoteName, targetBranch, repoDir, err)
+	}
+	return nil
+}
+
+func GitRevParse(ctx context.Context, repoDir, rev string) (hash string, err error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "rev-parse", "--verify", rev)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s in %s: %w", rev, repoDir, err)
+	}
+	lines = removeEmptyLines(lines)
+	if len(lines) != 1 {
+		return "", fmt.Errorf("expected only one line when resolving %s: %s", rev, strings.Join(lines, "\n"))
+	}
+	return lines[0], nil
+}
+
+// GitCommonDir returns the absolute path of the git directory that is shared by all worktrees of the repository
+// that contains the directory.
+func GitCommonDir(ctx context.Context, dir string) (string, error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, dir, "git", "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", fmt.Errorf("failed to find the git directory of %s: %w", dir, err)
+	}
+	lines = removeEmptyLines(lines)
+	if len(lines) != 1 {
+		return "", fmt.Errorf("expected only one line when finding the git directory of %s: %s", dir, strings.Join(lines, "\n"))
+	}
+	if filepath.IsAbs(lines[0]) {
+		return lines[0], nil
+	}
+	return filepath.Abs(filepath.Join(dir, lines[0]))
+}
+
+// GitStashCreate creates a stash commit of the index and the working tree without
+// modifying them. The hash is empty in case there are no local changes.
+func GitStashCreate(ctx context.Context, repoDir string) (hash string, err error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "stash", "create")
+	if err != nil {
+		return "", fmt.Errorf("failed to create stash commit in %s: %w", repoDir, err)
+	}
+	lines = removeEmptyLines(lines)
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return lines[0], nil
+}
+
+func GitStashApply(ctx context.Context, repoDir, stash string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "stash", "apply", "--index", stash)
+	if err != nil {
+		return fmt.Errorf("failed to apply stash %s in %s: %w", stash, repoDir, err)
+	}
+	return nil
+}
+
+func GitUpdateRef(ctx context.Context, repoDir, ref, hash string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "update-ref", ref, hash)
+	if err != nil {
+		return fmt.Errorf("failed to update ref %s in %s: %w", ref, repoDir, err)
+	}
+	return nil
+}
+
+func GitDeleteRef(ctx context.Context, repoDir, ref string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "update-ref", "-d", ref)
+	if err != nil {
+		return fmt.Errorf("failed to delete ref %s in %s: %w", ref, repoDir, err)
+	}
+	return nil
+}
+
+func GitResetHard(ctx context.Context, repoDir, rev string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "reset", "--hard", rev)
+	if err != nil {
+		return fmt.Errorf("failed to reset %s to %s: %w", repoDir, rev, err)
+	}
+	return nil
+}
+
+// GitUntrackedFiles returns the untracked files that are not ignored, relative to the repository directory.
+func GitUntrackedFiles(ctx context.Context, repoDir string) ([]string, error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files in %s: %w", repoDir, err)
+	}
+	return removeEmptyLines(lines), nil
+}
+
+// GitChangedFiles returns all staged, unstaged and untracked files that are not ignored,
+// relative to the repository directory.
+func GitChangedFiles(ctx context.Context, repoDir string) ([]string, error) {
+	changed, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "-c", "core.quotepath=off", "diff", "--name-only", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files in %s: %w", repoDir, err)
+	}
+
+	untracked, err := GitUntrackedFiles(ctx, repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := append(removeEmptyLines(changed), untracked...)
+	sort.Strings(files)
+	return files, nil
+}
+
+// GitStashPush stashes the local changes of the passed files including untracked files.
+func GitStashPush(ctx context.Context, repoDir, message string, files ...string) error {
+	args := []string{"stash", "push", "--include-untracked", "-m", message}
+	if len(files) > 0 {
+		args = append(append(args, "--"), files...)
+	}
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", args...)
+	if err != nil {
+		return fmt.Errorf("failed to stash local changes in %s: %w", repoDir, err)
+	}
+	return nil
+}
+
+// GitStashList returns the stash references, e.g. stash@{0}, and their subjects, e.g. On main: message
+func GitStashList(ctx context.Context, repoDir string) (refs, subjects []string, err error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "stash", "list", "--format=%gd %s")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list stashes in %s: %w", repoDir, err)
+	}
+	for _, line := range removeEmptyLines(lines) {
+		ref, subject, _ := strings.Cut(line, " ")
+		refs = append(refs, ref)
+		subjects = append(subjects, subject)
+	}
+	return refs, subjects, nil
+}
+
+func GitStashPop(ctx context.Context, repoDir, stash string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "stash", "pop", "--index", stash)
+	if err != nil {
+		return fmt.Errorf("failed to restore stash %s in %s: %w", stash, repoDir, err)
+	}
+	return nil
+}
+
+func GitFetch(ctx context.Context, repoDir, remoteName string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "fetch", "--prune", remoteName)
+	if err != nil {
+		return fmt.Errorf("git fetch failed in %s: %w", repoDir, err)
+	}
+	return nil
+}
+
+// GitClone clones the git url into the repository directory. A depth greater than 0 creates a shallow clone
+// of the branch, which is the default branch of the remote in case it is empty.
+func GitClone(ctx context.Context, gitUrl, repoDir, remoteName, branch string, depth int) error {
+	args := []string{"clone", "--origin", remoteName}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	if branch != "" {
+		args = append(args, "--branch", branch)
+	}
+	args = append(args, gitUrl, repoDir)
+
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, "", "git", args...)
+	if err != nil {
+		return fmt.Errorf("failed to clone %s into %s: %w", gitUrl, repoDir, err)
+	}
+	return nil
+}
+
+func GitIsShallow(ctx context.Context, repoDir string) (bool, error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return false, fmt.Errorf("failed to check if %s is a shallow clone: %w", repoDir, err)
+	}
+	lines = removeEmptyLines(lines)
+	return len(lines) == 1 && lines[0] == "true", nil
+}
+
+// GitFetchUnshallow fetches the complete history of a shallow clone from the git url.
+func GitFetchUnshallow(ctx context.Context, repoDir, gitUrl string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "fetch", "--unshallow", gitUrl)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the complete history of %s in %s: %w", gitUrl, repoDir, err)
+	}
+	return nil
+}
+
+// GitCheckoutRemoteBranch checks out the local branch and resets it to the tip of the remote branch.
+// The local branch is created in case it does not exist. Local commits that do not exist
+// on the remote branch are never discarded.
+func GitCheckoutRemoteBranch(ctx context.Context, repoDir, remoteName, branch string) error {
+	remoteBranch := fmt.Sprintf("%s/%s", remoteName, branch)
+	if !GitExistsBranch(ctx, repoDir, "refs/heads/"+branch) {
+		_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "checkout", "-b", branch, "--track", remoteBranch)
+		if err != nil {
+			return fmt.Errorf("failed to checkout %s in %s: %w", remoteBranch, repoDir, err)
+		}
+		return nil
+	}
+
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "rev-list", "--count", fmt.Sprintf("%s..%s", remoteBranch, branch))
+	if err != nil {
+		return fmt.Errorf("failed to compare %s with %s in %s: %w", branch, remoteBranch, repoDir, err)
+	}
+	lines = removeEmptyLines(lines)
+	if len(lines) != 1 || lines[0] != "0" {
+		return fmt.Errorf("local branch %s in %s has commits that do not exist on %s, refusing to reset it", branch, repoDir, remoteBranch)
+	}
+
+	err = GitCheckoutBranch(ctx, repoDir, branch)
+	if err != nil {
+		return err
+	}
+	return GitResetHard(ctx, repoDir, remoteBranch)
+}
+
+// GitCheckBranchPushed returns an error in case the local branch has commits that are reachable from none of the refs,
+// which would be lost when the branch is deleted or reset. Refs that do not exist are ignored.
+func GitCheckBranchPushed(ctx context.Context, repoDir, branch string, refs ...string) error {
+	args := []string{"rev-list", "--count", "refs/heads/" + branch, "--not"}
+	for _, ref := range refs {
+		if GitExistsBranch(ctx, repoDir, ref) {
+			args = append(args, ref)
+		}
+	}
+
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", args...)
+	if err != nil {
+		return fmt.Errorf("failed to compare %s with %s in %s: %w", branch, strings.Join(refs, ", "), repoDir, err)
+	}
+	lines = removeEmptyLines(lines)
+	if len(lines) != 1 || lines[0] != "0" {
+		return fmt.Errorf("%w: local branch %s in %s has commits that do not exist on %s, refusing to reset it", ErrUnpushedCommits, branch, repoDir, strings.Join(refs, " or "))
+	}
+	return nil
+}
+
 func GitGetDefaultBranch(ctx context.Context, repoDir, remoteName string) (branchName string, err error) {
 	// remoteName is usually origin
 	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "rev-parse", "--abbrev-ref", fmt.Sprintf("%s/HEAD", remoteName))
+	if err != nil {
+		// <remote>/HEAD only exists in cloned repositories, ask the remote otherwise
+		_, e := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "remote", "set-head", remoteName, "--auto")
+		if e == nil {
+			lines, err = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "rev-parse", "--abbrev-ref", fmt.Sprintf("%s/HEAD", remoteName))
+		}
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to get default branch in %s: %w", repoDir, err)
 	}
@@ -315,7 +738,8 @@ func GitPushTags(ctx context.Context, repoDir, remoteName string) (err error) {
 
 // Creates a new local version tag BUT does NOT push it.
 // Use GitPushTags(ctx, repoDir, remoteName) to also push the tag to the origin
-func GitBumpVersionTag(ctx context.Context, repoDir, remoteName string, major, minor, patch bool) (err error) {
+// The tag is created on the releaseBranch or on the remote default branch in case releaseBranch is empty.
+func GitBumpVersionTag(ctx context.Context, repoDir, remoteName, releaseBranch string, major, minor, patch bool) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("failed to bump release tag in %s: %w", repoDir, err)
@@ -327,9 +751,12 @@ func GitBumpVersionTag(ctx context.Context, repoDir, remoteName string, major, m
 		return err
 	}
 
-	mainBranch, err := GitGetDefaultBranch(ctx, repoDir, remoteName)
-	if err != nil {
-		return
+	mainBranch := releaseBranch
+	if mainBranch == "" {
+		mainBranch, err = GitGetDefaultBranch(ctx, repoDir, remoteName)
+		if err != nil {
+			return
+		}
 	}
 
 	err = GitCheckoutBranch(ctx, repoDir, mainBranch)
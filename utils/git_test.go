@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jxsl13/module-migration/internal/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+var git = gittest.Git
+
+// newBareRemote creates a bare repository with one commit on each branch.
+func newBareRemote(t *testing.T, branches ...string) string {
+	t.Helper()
+	remoteDir := gittest.NewRemote(t)
+	for _, branch := range branches {
+		gittest.Commit(t, remoteDir, branch, map[string]string{strings.ReplaceAll(branch, "/", "-") + ".txt": branch})
+	}
+	return remoteDir
+}
+
+func TestGitSwitchRemote(t *testing.T) {
+	ctx := context.Background()
+	oldUrl := newBareRemote(t, "main")
+	newUrl := newBareRemote(t, "main")
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	git(t, "", "clone", oldUrl, repoDir)
+
+	err := GitSwitchRemote(ctx, repoDir, "origin", "legacy", newUrl)
+	require.NoError(t, err)
+	require.Equal(t, newUrl, git(t, repoDir, "remote", "get-url", "origin"))
+	require.Equal(t, oldUrl, git(t, repoDir, "remote", "get-url", "legacy"))
+	require.Equal(t, "origin", git(t, repoDir, "config", "branch.main.remote"))
+	require.Equal(t, "+refs/heads/*:refs/remotes/origin/*", git(t, repoDir, "config", "--get-all", "remote.origin.fetch"))
+
+	// an empty legacy name changes the url in place
+	repoDir = filepath.Join(t.TempDir(), "repo")
+	git(t, "", "clone", oldUrl, repoDir)
+
+	err = GitSwitchRemote(ctx, repoDir, "origin", "", newUrl)
+	require.NoError(t, err)
+	require.Equal(t, newUrl, git(t, repoDir, "remote", "get-url", "origin"))
+	require.Equal(t, "origin", git(t, repoDir, "remote"))
+	require.Equal(t, "origin", git(t, repoDir, "config", "branch.main.remote"))
+}
+
+func TestGitSwitchRemoteRollback(t *testing.T) {
+	ctx := context.Background()
+	oldUrl := newBareRemote(t, "main")
+	// the fetch of the main branch fails after the remote was switched
+	newUrl := newBareRemote(t, "other")
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	git(t, "", "clone", oldUrl, repoDir)
+	refspec := "+refs/heads/main:refs/remotes/origin/main"
+	git(t, repoDir, "config", "remote.origin.fetch", refspec)
+
+	err := GitSwitchRemote(ctx, repoDir, "origin", "legacy", newUrl)
+	require.Error(t, err)
+
+	require.Equal(t, "origin", git(t, repoDir, "remote"))
+	require.Equal(t, oldUrl, git(t, repoDir, "remote", "get-url", "origin"))
+	require.Equal(t, refspec, git(t, repoDir, "config", "--get-all", "remote.origin.fetch"))
+	require.Equal(t, "origin", git(t, repoDir, "config", "branch.main.remote"))
+	require.Equal(t, "refs/heads/main", git(t, repoDir, "config", "branch.main.merge"))
+	require.Equal(t, "origin/main", git(t, repoDir, "rev-parse", "--abbrev-ref", "main@{upstream}"))
+}
+
+func TestGitCloneShallow(t *testing.T) {
+	ctx := context.Background()
+	oldDir := newBareRemote(t, "main")
+	gittest.Commit(t, oldDir, "main", map[string]string{"README.md": "second"})
+	newDir := gittest.NewRemote(t)
+
+	// local paths are never cloned shallow
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	err := GitClone(ctx, gittest.URL(oldDir), repoDir, "origin", "main", 1)
+	require.NoError(t, err)
+
+	shallow, err := GitIsShallow(ctx, repoDir)
+	require.NoError(t, err)
+	require.True(t, shallow)
+	require.Equal(t, "1", git(t, repoDir, "rev-list", "--count", "HEAD"))
+
+	// the new repository does not contain the history of the shallow clone
+	err = GitSwitchRemote(ctx, repoDir, "origin", "", newDir)
+	require.NoError(t, err)
+	require.ErrorIs(t, GitPushUpstream(ctx, repoDir, "origin", "main"), ErrShallowUpdate)
+
+	err = GitFetchUnshallow(ctx, repoDir, gittest.URL(oldDir))
+	require.NoError(t, err)
+	shallow, err = GitIsShallow(ctx, repoDir)
+	require.NoError(t, err)
+	require.False(t, shallow)
+
+	require.NoError(t, GitPushUpstream(ctx, repoDir, "origin", "main"))
+	require.Equal(t, "2", git(t, newDir, "rev-list", "--count", "main"))
+
+	// other push failures are no shallow update rejections
+	git(t, repoDir, "remote", "set-url", "origin", filepath.Join(t.TempDir(), "missing.git"))
+	err = GitPushUpstream(ctx, repoDir, "origin", "main")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrShallowUpdate)
+}
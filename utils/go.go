@@ -3,8 +3,57 @@ package utils
 import (
 	"context"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
+// FindGoModDirs returns the directories of all Go modules of the repository, the root directory first.
+// Hidden, vendor and testdata directories as well as nested git repositories are skipped,
+// because the go command ignores them or they belong to other repositories.
+func FindGoModDirs(repoDir string) ([]string, error) {
+	dirs := make([]string, 0, 1)
+	err := filepath.WalkDir(repoDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			if d.Name() == "go.mod" && d.Type().IsRegular() {
+				dirs = append(dirs, filepath.Dir(path))
+			}
+			return nil
+		}
+
+		if path == repoDir {
+			return nil
+		}
+
+		name := d.Name()
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata" {
+			return filepath.SkipDir
+		}
+
+		_, found, err := Exists(filepath.Join(path, ".git"))
+		if err != nil {
+			return err
+		}
+		if found {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// parent directories are sorted before their sub directories
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
 func GoModTidy(ctx context.Context, repoDir string) error {
 	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "go", "mod", "tidy")
 	if err != nil {
@@ -14,17 +63,36 @@ func GoModTidy(ctx context.Context, repoDir string) error {
 }
 
 func GoBuildAll(ctx context.Context, repoDir string) error {
-	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "go", "build", "./...")
+	// discard the binary in case the repository only contains a single main package
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "go", "build", "-o", os.DevNull, "./...")
 	if err != nil {
 		return fmt.Errorf("go build ./... failed for repo %s: %w", repoDir, err)
 	}
 	return nil
 }
 
-func GoGet(ctx context.Context, repoDir string, dependency string) error {
-	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "go", "get", dependency)
+// GoModuleVersion resolves a module query, e.g. an exact version, a version prefix like v1.2 or latest,
+// to the version of the module.
+func GoModuleVersion(ctx context.Context, repoDir, modulePath, query string) (string, error) {
+	// the renamed requirements have no go.sum entries yet, which is refused in the default readonly mode
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "go", "list", "-mod=mod", "-m", "-f", "{{.Version}}", modulePath+"@"+query)
+	if err != nil {
+		return "", fmt.Errorf("go list -m %s@%s failed for repo %s: %w", modulePath, query, repoDir, err)
+	}
+
+	// download messages are printed before the version
+	lines = removeEmptyLines(lines)
+	if len(lines) == 0 {
+		return "", fmt.Errorf("go list -m %s@%s returned no version for repo %s", modulePath, query, repoDir)
+	}
+	return lines[len(lines)-1], nil
+}
+
+// GoModRequire sets the required version of the module, the indirect marker is kept.
+func GoModRequire(ctx context.Context, repoDir, modulePath, version string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "go", "mod", "edit", "-require="+modulePath+"@"+version)
 	if err != nil {
-		return fmt.Errorf("go get %s failed for repo %s: %w", repoDir, dependency, err)
+		return fmt.Errorf("go mod edit -require %s@%s failed for repo %s: %w", modulePath, version, repoDir, err)
 	}
 	return nil
 }
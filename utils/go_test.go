@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindGoModDirs(t *testing.T) {
+	repoDir := t.TempDir()
+	for _, dir := range []string{".", "api", "tools/lint", "vendor/x", "testdata/x", ".hidden", "nested"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repoDir, dir), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, dir, "go.mod"), []byte("module x\n"), 0o644))
+	}
+	// nested git repository
+	require.NoError(t, os.Mkdir(filepath.Join(repoDir, "nested", ".git"), 0o755))
+
+	dirs, err := FindGoModDirs(repoDir)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		repoDir,
+		filepath.Join(repoDir, "api"),
+		filepath.Join(repoDir, "tools", "lint"),
+	}, dirs)
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MirrorRefspecs are the refs that are pushed to a mirror: all branches, tags and notes.
+var MirrorRefspecs = []string{
+	"refs/heads/*:refs/heads/*",
+	"refs/tags/*:refs/tags/*",
+	"refs/notes/*:refs/notes/*",
+}
+
+// GitCloneMirror creates a bare mirror clone of all refs of the git url.
+func GitCloneMirror(ctx context.Context, gitUrl, repoDir string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, "", "git", "clone", "--mirror", gitUrl, repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to mirror %s into %s: %w", gitUrl, repoDir, err)
+	}
+	return nil
+}
+
+// GitPushRefs pushes the refspecs to the git url without overwriting diverged refs.
+func GitPushRefs(ctx context.Context, repoDir, gitUrl string, refspecs ...string) error {
+	args := append([]string{"push", gitUrl}, refspecs...)
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", args...)
+	if err != nil {
+		return fmt.Errorf("failed to push %s to %s in %s: %w", strings.Join(refspecs, " "), gitUrl, repoDir, err)
+	}
+	return nil
+}
+
+// GitLsRemote returns the commit hashes of all branches, tags and notes of the git url by ref name.
+func GitLsRemote(ctx context.Context, repoDir, gitUrl string) (map[string]string, error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "ls-remote", gitUrl, "refs/heads/*", "refs/tags/*", "refs/notes/*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs of %s: %w", gitUrl, err)
+	}
+
+	refs := make(map[string]string, len(lines))
+	for _, line := range removeEmptyLines(lines) {
+		hash, ref, found := strings.Cut(line, "\t")
+		if !found {
+			continue
+		}
+		refs[ref] = hash
+	}
+	return refs, nil
+}
+
+func GitLfsFetchAll(ctx context.Context, repoDir, gitUrl string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "lfs", "fetch", "--all", gitUrl)
+	if err != nil {
+		return fmt.Errorf("failed to fetch LFS objects of %s in %s: %w", gitUrl, repoDir, err)
+	}
+	return nil
+}
+
+func GitLfsPushAll(ctx context.Context, repoDir, gitUrl string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "lfs", "push", "--all", gitUrl)
+	if err != nil {
+		return fmt.Errorf("failed to push LFS objects to %s in %s: %w", gitUrl, repoDir, err)
+	}
+	return nil
+}
+
+// RefMismatch is a ref of the source that is missing or different in the target.
+type RefMismatch struct {
+	Ref    string
+	Source string
+	// Target is empty in case the ref is missing
+	Target string
+}
+
+func (m RefMismatch) String() string {
+	if m.Target == "" {
+		return fmt.Sprintf("%s: missing", m.Ref)
+	}
+	return fmt.Sprintf("%s: %s instead of %s", m.Ref, m.Target, m.Source)
+}
+
+// CompareRefs returns all refs of the source that are missing or point to a different commit in the target,
+// sorted by ref name. Additional refs of the target are no mismatch.
+func CompareRefs(source, target map[string]string) []RefMismatch {
+	mismatches := make([]RefMismatch, 0)
+	for ref, hash := range source {
+		if target[ref] == hash {
+			continue
+		}
+		mismatches = append(mismatches, RefMismatch{
+			Ref:    ref,
+			Source: hash,
+			Target: target[ref],
+		})
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Ref < mismatches[j].Ref
+	})
+	return mismatches
+}
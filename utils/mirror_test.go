@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareRefs(t *testing.T) {
+	source := map[string]string{
+		"refs/heads/main":    "a",
+		"refs/tags/v1.0.0":   "b",
+		"refs/notes/commits": "c",
+	}
+	target := map[string]string{
+		"refs/heads/main":         "a",
+		"refs/tags/v1.0.0":        "x",
+		"refs/heads/chore/module": "d",
+	}
+
+	mismatches := CompareRefs(source, target)
+	require.Equal(t, []RefMismatch{
+		{Ref: "refs/notes/commits", Source: "c"},
+		{Ref: "refs/tags/v1.0.0", Source: "b", Target: "x"},
+	}, mismatches)
+	require.Equal(t, "refs/notes/commits: missing", mismatches[0].String())
+
+	require.Empty(t, CompareRefs(source, source))
+}
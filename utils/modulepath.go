@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	giturls "github.com/whilp/git-urls"
+)
+
+const (
+	// AnyModuleHost is the host of a module path rule that applies to all hosts without an explicit rule.
+	AnyModuleHost = "*"
+
+	// GitSuffixStrip removes the .git suffix of the repository from the module path
+	GitSuffixStrip = "strip"
+	// GitSuffixKeep keeps the .git suffix in case the git url has one
+	GitSuffixKeep = "keep"
+)
+
+// ModulePathRule configures how module paths are derived from the git urls of a host.
+// The zero value removes scheme, user and .git suffix only.
+type ModulePathRule struct {
+	// StripPort removes the port, e.g. of Bitbucket Server ssh urls like git.company.com:7999
+	StripPort bool
+	// StripPrefix removes the leading path segments, e.g. scm of Bitbucket Server http urls
+	StripPrefix string
+	// Lowercase converts the whole module path to lower case
+	Lowercase bool
+	// Subgroups adds the .git suffix to repositories in GitLab subgroups (group/subgroup/repo),
+	// because the go command cannot find their repository root otherwise.
+	Subgroups bool
+	// GitSuffix is either GitSuffixStrip or GitSuffixKeep, empty strips the suffix
+	GitSuffix string
+}
+
+// ModulePathRules maps lower case host names without port to their module path rules.
+type ModulePathRules map[string]ModulePathRule
+
+// ModulePathRulesDescription is the description of the module path rules flag of every subcommand.
+const ModulePathRulesDescription = "',' separated list of per host module path derivation rules host=option+option with the options strip-port, strip-prefix:<path>, lowercase, subgroups and git-suffix:strip|keep, e.g. git.company.com=strip-port+strip-prefix:scm,*=lowercase"
+
+// ParseModulePathRules parses a list of host=option+option entries, e.g.
+// git.company.com=strip-port+strip-prefix:scm+lowercase or gitlab.company.com=subgroups.
+// The options are strip-port, strip-prefix:<path>, lowercase, subgroups and git-suffix:strip|keep.
+func ParseModulePathRules(entries []string) (ModulePathRules, error) {
+	rules := make(ModulePathRules, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		host, options, found := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !found || host == "" || strings.TrimSpace(options) == "" {
+			return nil, fmt.Errorf("invalid module path rule, expected <host>=<option>+<option>: %s", entry)
+		}
+
+		var rule ModulePathRule
+		for _, option := range strings.Split(options, "+") {
+			name, value, hasValue := strings.Cut(strings.TrimSpace(option), ":")
+			switch {
+			case name == "strip-port" && !hasValue:
+				rule.StripPort = true
+			case name == "strip-prefix" && strings.Trim(value, "/") != "":
+				rule.StripPrefix = strings.Trim(value, "/")
+			case name == "lowercase" && !hasValue:
+				rule.Lowercase = true
+			case name == "subgroups" && !hasValue:
+				rule.Subgroups = true
+			case name == "git-suffix" && (value == GitSuffixStrip || value == GitSuffixKeep):
+				rule.GitSuffix = value
+			default:
+				return nil, fmt.Errorf("invalid module path rule option %q of %s, expected one of strip-port, strip-prefix:<path>, lowercase, subgroups or git-suffix:%s|%s", option, host, GitSuffixStrip, GitSuffixKeep)
+			}
+		}
+		rules[host] = rule
+	}
+	return rules, nil
+}
+
+// Rule returns the rule of the host or the rule of AnyModuleHost.
+func (r ModulePathRules) Rule(host string) ModulePathRule {
+	if rule, ok := r[strings.ToLower(host)]; ok {
+		return rule
+	}
+	return r[AnyModuleHost]
+}
+
+// ToModuleUrl derives the module path of a git url with the rule of its host.
+func (r ModulePathRules) ToModuleUrl(gitUrl string) (string, error) {
+	u, err := giturls.Parse(gitUrl)
+	if err != nil {
+		return "", fmt.Errorf("invalid git url: %s: %w", gitUrl, err)
+	}
+
+	rule := r.Rule(u.Hostname())
+	u.Scheme = ""
+	u.User = nil
+	if rule.StripPort {
+		u.Host = u.Hostname()
+	}
+
+	p := strings.Trim(u.Path, "/")
+	hasSuffix := strings.HasSuffix(p, ".git")
+	p = strings.TrimSuffix(p, ".git")
+	if rule.StripPrefix != "" {
+		p = strings.TrimPrefix(p, rule.StripPrefix+"/")
+	}
+	if hasSuffix && rule.GitSuffix == GitSuffixKeep || rule.Subgroups && strings.Count(p, "/") > 1 {
+		p += ".git"
+	}
+	u.Path = "/" + p
+	u.RawPath = ""
+
+	modulePath := strings.TrimLeft(u.String(), "/")
+	if rule.Lowercase {
+		modulePath = strings.ToLower(modulePath)
+	}
+	return modulePath, nil
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+type outputKey struct{}
+
+type output struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// WithOutput returns a context whose Stdout and Stderr writers are replaced.
+// This allows to buffer the output of concurrently processed repositories.
+func WithOutput(ctx context.Context, stdout, stderr io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, output{
+		stdout: stdout,
+		stderr: stderr,
+	})
+}
+
+// Stdout returns the standard output writer of the context or os.Stdout.
+func Stdout(ctx context.Context) io.Writer {
+	if o, ok := ctx.Value(outputKey{}).(output); ok {
+		return o.stdout
+	}
+	return os.Stdout
+}
+
+// Stderr returns the error output writer of the context or os.Stderr.
+func Stderr(ctx context.Context) io.Writer {
+	if o, ok := ctx.Value(outputKey{}).(output); ok {
+		return o.stderr
+	}
+	return os.Stderr
+}
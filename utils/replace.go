@@ -1,13 +1,14 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
+	"go/format"
 	"go/parser"
-	"go/printer"
 	"go/token"
-	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
@@ -15,110 +16,169 @@ import (
 	"golang.org/x/tools/go/ast/astutil"
 )
 
-func ReplaceInDir(rootPath string, exclude, include []*regexp.Regexp, replacer *strings.Replacer) ([]string, error) {
-	touchedFiles := make([]string, 0, 512)
+// FileChange describes the content of a file before and after a modification.
+// Before is nil in case the file does not exist yet.
+type FileChange struct {
+	Path   string
+	Before []byte
+	After  []byte
+}
+
+func (c FileChange) Changed() bool {
+	return c.Before == nil || !bytes.Equal(c.Before, c.After)
+}
+
+// Write persists the new content of the file.
+func (c FileChange) Write() error {
+	err := os.WriteFile(c.Path, c.After, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to write to %s: %w", c.Path, err)
+	}
+	return nil
+}
+
+// Diff returns a unified diff of the change with file paths relative to baseDir.
+func (c FileChange) Diff(baseDir string) string {
+	rel, err := filepath.Rel(baseDir, c.Path)
+	if err != nil {
+		rel = c.Path
+	}
+	rel = filepath.ToSlash(rel)
+
+	oldName := "a/" + rel
+	if c.Before == nil {
+		oldName = "/dev/null"
+	}
+	return UnifiedDiff(oldName, "b/"+rel, c.Before, c.After)
+}
+
+// ReplaceInDir replaces all matching imports and text occurrences in the matching files
+// and returns the sorted list of modified files as well as all ambiguous matches that were not replaced.
+func ReplaceInDir(rootPath string, exclude, include []*regexp.Regexp, replacer *Replacer) ([]string, []AmbiguousMatch, error) {
+	changes, ambiguous, err := ComputeReplaceInDir(rootPath, exclude, include, replacer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	touchedFiles := make([]string, 0, len(changes))
+	for _, c := range changes {
+		err = c.Write()
+		if err != nil {
+			return touchedFiles, ambiguous, err
+		}
+		touchedFiles = append(touchedFiles, c.Path)
+	}
+	return touchedFiles, ambiguous, nil
+}
+
+// ComputeReplaceInDir does the same as ReplaceInDir but does not modify any files.
+// It returns the changes that would be applied, sorted by their file paths.
+func ComputeReplaceInDir(rootPath string, exclude, include []*regexp.Regexp, replacer *Replacer) ([]FileChange, []AmbiguousMatch, error) {
+	changes := make([]FileChange, 0, 64)
+	ambiguous := make([]AmbiguousMatch, 0)
 	fset := token.NewFileSet()
 	err := WalkMatching(rootPath, exclude, include, func(path string, info fs.FileInfo, e error) (err error) {
 		if e != nil {
 			return fmt.Errorf("%s: %w", path, e)
 		}
-		defer func() {
-			touchedFiles = append(touchedFiles, path)
-		}()
-
-		if !strings.HasSuffix(path, ".go") {
-			e = replaceInNonGoFile(path, replacer)
-			if e != nil {
-				return e
-			}
-			return nil
-		}
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
 
-		f, err := parser.ParseFile(fset, path, data, parser.AllErrors|parser.ParseComments)
+		data, err := os.ReadFile(path)
 		if err != nil {
-			return fmt.Errorf("invalid Go file: %s: %w", path, err)
+			return fmt.Errorf("failed to read %s: %w", path, err)
 		}
 
-		imports := astutil.Imports(fset, f)
-
-		for _, imps := range imports {
-			for _, imp := range imps {
-				name := ""
-				if imp.Name != nil {
-					name = imp.Name.Name
-				}
-				before := strings.Trim(imp.Path.Value, `"`)
-				after := replacer.Replace(before)
-				if after != before {
-
-					if name != "" {
-						astutil.DeleteNamedImport(fset, f, name, before)
-						astutil.AddNamedImport(fset, f, name, after)
-					} else {
-						astutil.DeleteImport(fset, f, before)
-						astutil.AddImport(fset, f, after)
-					}
-
-				}
+		var (
+			replaced []byte
+			skipped  []AmbiguousMatch
+		)
+		if strings.HasSuffix(path, ".go") {
+			replaced, skipped, err = replaceInGoFile(fset, path, data, replacer)
+			if err != nil {
+				return err
 			}
-
+		} else {
+			var text string
+			text, skipped = replacer.ReplaceWithReport(string(data))
+			replaced = []byte(text)
 		}
 
-		file, err := os.OpenFile(path, os.O_RDWR|os.O_TRUNC, 0755)
-		if err != nil {
-			return err
+		for _, a := range skipped {
+			a.Path = path
+			ambiguous = append(ambiguous, a)
 		}
-		defer file.Close()
 
-		err = printer.Fprint(file, fset, f)
-		if err != nil {
-			return err
+		c := FileChange{
+			Path:   path,
+			Before: data,
+			After:  replaced,
+		}
+		if c.Changed() {
+			changes = append(changes, c)
 		}
-
 		return nil
 	})
 
-	sort.Sort(byPathSeparators(touchedFiles))
-	return touchedFiles, err
-}
-
-func sortedKeys[V any](m map[string]V) []string {
-	result := make([]string, 0, len(m))
-	for k := range m {
-		result = append(result, k)
-	}
-
-	sort.Strings(result)
-	return result
+	sort.Slice(changes, func(i, j int) bool {
+		return lessPathSeparators(changes[i].Path, changes[j].Path)
+	})
+	return changes, ambiguous, err
 }
 
-func replaceInNonGoFile(path string, replacer *strings.Replacer) error {
-	f, err := os.OpenFile(path, os.O_RDWR, 0)
+// replaceInGoFile only replaces import paths in Go files
+func replaceInGoFile(fset *token.FileSet, path string, data []byte, replacer *Replacer) ([]byte, []AmbiguousMatch, error) {
+	f, err := parser.ParseFile(fset, path, data, parser.AllErrors|parser.ParseComments)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("invalid Go file: %s: %w", path, err)
 	}
-	defer f.Close()
 
-	data, err := io.ReadAll(f)
-	if err != nil {
-		return fmt.Errorf("failed to read %s: %w", path, err)
+	var ambiguous []AmbiguousMatch
+	changed := false
+	imports := astutil.Imports(fset, f)
+	for _, imps := range imports {
+		for _, imp := range imps {
+			name := ""
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			before := strings.Trim(imp.Path.Value, `"`)
+			after, skipped := replacer.ReplaceWithReport(before)
+			for _, a := range skipped {
+				a.Line = fset.Position(imp.Pos()).Line
+				ambiguous = append(ambiguous, a)
+			}
+			if after == before {
+				continue
+			}
+			changed = true
+
+			if name != "" {
+				astutil.DeleteNamedImport(fset, f, name, before)
+				astutil.AddNamedImport(fset, f, name, after)
+			} else {
+				astutil.DeleteImport(fset, f, before)
+				astutil.AddImport(fset, f, after)
+			}
+		}
 	}
-	err = f.Truncate(0)
-	if err != nil {
-		return fmt.Errorf("failed to truncate: %s: %w", path, err)
+
+	if !changed {
+		return data, ambiguous, nil
 	}
-	_, err = f.Seek(0, 0)
+
+	var buf bytes.Buffer
+	err = format.Node(&buf, fset, f)
 	if err != nil {
-		return fmt.Errorf("failed to reset file cursor to 0: %s: %w", path, err)
+		return nil, nil, fmt.Errorf("failed to format %s: %w", path, err)
 	}
+	return buf.Bytes(), ambiguous, nil
+}
 
-	_, err = replacer.WriteString(f, string(data))
-	if err != nil {
-		return fmt.Errorf("failed to write replaced file content of %s: %w", path, err)
+func sortedKeys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
 	}
-	return nil
+
+	sort.Strings(result)
+	return result
 }
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SnapshotRef keeps the stash commit of a snapshot reachable until the snapshot is released.
+const SnapshotRef = "refs/module-migration/snapshot"
+
+// Snapshot is the state of a repository before it was changed.
+type Snapshot struct {
+	RepoDir string
+	// Branch is the branch that was checked out or HEAD in case of a detached HEAD
+	Branch string
+	// Head is the commit that was checked out
+	Head string
+	// Stash is the stash commit of local changes or empty in case there were none
+	Stash string
+	// Untracked are the untracked files that already existed
+	Untracked []string
+	// Branches are the commits of branches that may be created or moved, empty for branches that did not exist
+	Branches map[string]string
+	// Backups are the contents of untracked and ignored files before they were changed,
+	// because they are neither part of the checked out commit nor of the stash commit
+	Backups map[string][]byte
+}
+
+// TakeSnapshot records the checked out commit, the local changes and the untracked files
+// of the repository without modifying it. The passed branches are restored or removed together with the repository.
+func TakeSnapshot(ctx context.Context, repoDir string, branches ...string) (*Snapshot, error) {
+	branch, err := GitGetBranchName(ctx, repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := GitRevParse(ctx, repoDir, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	stash, err := GitStashCreate(ctx, repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := GitUntrackedFiles(ctx, repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make(map[string]string, len(branches))
+	for _, b := range branches {
+		commits[b] = ""
+		if GitExistsBranch(ctx, repoDir, "refs/heads/"+b) {
+			commits[b], err = GitRevParse(ctx, repoDir, "refs/heads/"+b)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ref := head
+	if stash != "" {
+		ref = stash
+	}
+	err = GitUpdateRef(ctx, repoDir, SnapshotRef, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		RepoDir:   repoDir,
+		Branch:    branch,
+		Head:      head,
+		Stash:     stash,
+		Untracked: untracked,
+		Branches:  commits,
+		Backups:   make(map[string][]byte),
+	}, nil
+}
+
+// Backup records the previous content of the changed files that are untracked or ignored.
+// Files that were already recorded keep their first content.
+func (s *Snapshot) Backup(ctx context.Context, changes []FileChange) error {
+	paths := make([]string, 0, len(changes))
+	before := make(map[string][]byte, len(changes))
+	for _, c := range changes {
+		rel, err := filepath.Rel(s.RepoDir, c.Path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if _, found := s.Backups[rel]; found || c.Before == nil {
+			continue
+		}
+		paths = append(paths, rel)
+		before[rel] = c.Before
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	// tracked files are restored by the checked out commit and the stash commit
+	tracked, err := ExecuteQuietPathApplicationWithOutput(ctx, s.RepoDir, "git", append([]string{"ls-files", "--"}, paths...)...)
+	if err != nil {
+		return fmt.Errorf("failed to list tracked files in %s: %w", s.RepoDir, err)
+	}
+	for _, f := range removeEmptyLines(tracked) {
+		delete(before, f)
+	}
+
+	for f, data := range before {
+		s.Backups[f] = data
+	}
+	return nil
+}
+
+// Restore resets the repository to the snapshot: the checked out branch, its commit, the local changes,
+// the recorded branches and the backups of untracked and ignored files are restored and all untracked
+// files that did not exist before are removed.
+func (s *Snapshot) Restore(ctx context.Context) error {
+	checkout := s.Branch
+	if checkout == "HEAD" {
+		checkout = s.Head
+	}
+
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, s.RepoDir, "git", "checkout", "--force", checkout)
+	if err != nil {
+		return fmt.Errorf("failed to checkout %s in %s: %w", checkout, s.RepoDir, err)
+	}
+
+	err = GitResetHard(ctx, s.RepoDir, s.Head)
+	if err != nil {
+		return err
+	}
+
+	untracked, err := GitUntrackedFiles(ctx, s.RepoDir)
+	if err != nil {
+		return err
+	}
+
+	existed := make(map[string]bool, len(s.Untracked))
+	for _, f := range s.Untracked {
+		existed[f] = true
+	}
+
+	errs := make([]error, 0)
+	for _, f := range untracked {
+		if existed[f] {
+			continue
+		}
+		err = os.Remove(filepath.Join(s.RepoDir, filepath.FromSlash(f)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w", f, err))
+		}
+	}
+	for f, data := range s.Backups {
+		err = os.WriteFile(filepath.Join(s.RepoDir, filepath.FromSlash(f)), data, 0666)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", f, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	err = s.restoreBranches(ctx)
+	if err != nil {
+		return err
+	}
+
+	if s.Stash != "" {
+		err = GitStashApply(ctx, s.RepoDir, s.Stash)
+		if err != nil {
+			return err
+		}
+	}
+	return s.Release(ctx)
+}
+
+// restoreBranches moves the recorded branches back to their commits and removes the branches that did not exist.
+// The checked out branch was already restored.
+func (s *Snapshot) restoreBranches(ctx context.Context) error {
+	for branch, commit := range s.Branches {
+		switch {
+		case branch == s.Branch:
+			continue
+		case commit != "":
+			_, err := ExecuteQuietPathApplicationWithOutput(ctx, s.RepoDir, "git", "branch", "--force", branch, commit)
+			if err != nil {
+				return fmt.Errorf("failed to restore branch %s in %s: %w", branch, s.RepoDir, err)
+			}
+		case GitExistsBranch(ctx, s.RepoDir, "refs/heads/"+branch):
+			err := GitDeleteBranch(ctx, s.RepoDir, branch)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Release removes the reference that keeps the snapshot reachable.
+func (s *Snapshot) Release(ctx context.Context) error {
+	return GitDeleteRef(ctx, s.RepoDir, SnapshotRef)
+}
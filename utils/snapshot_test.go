@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	remoteUrl := newBareRemote(t, "main", "chore/module")
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	git(t, "", "clone", remoteUrl, repoDir)
+	git(t, repoDir, "branch", "existing", "origin/chore/module")
+
+	write := func(name, content string) FileChange {
+		path := filepath.Join(repoDir, name)
+		before, _ := os.ReadFile(path)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0666))
+		return FileChange{Path: path, Before: before, After: []byte(content)}
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(repoDir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	write(".gitignore", "ignored.txt\n")
+	git(t, repoDir, "add", ".gitignore")
+	write("main.txt", "local change")
+	write("untracked.txt", "untracked")
+	write("ignored.txt", "ignored")
+	existing := git(t, repoDir, "rev-parse", "existing")
+
+	snapshot, err := TakeSnapshot(ctx, repoDir, "chore/migration", "existing")
+	require.NoError(t, err)
+
+	// the migration creates a branch, moves another one and changes tracked, untracked and ignored files
+	git(t, repoDir, "checkout", "-b", "chore/migration")
+	git(t, repoDir, "branch", "--force", "existing", "main")
+	changes := []FileChange{
+		write("main.txt", "migrated"),
+		write("untracked.txt", "migrated"),
+		write("ignored.txt", "migrated"),
+		write("new.txt", "migrated"),
+	}
+	require.NoError(t, snapshot.Backup(ctx, changes))
+	require.Len(t, snapshot.Backups, 2)
+
+	require.NoError(t, snapshot.Restore(ctx))
+
+	require.Equal(t, "main", git(t, repoDir, "branch", "--show-current"))
+	require.Equal(t, "local change", read("main.txt"))
+	require.Equal(t, "untracked", read("untracked.txt"))
+	require.Equal(t, "ignored", read("ignored.txt"))
+	require.NoFileExists(t, filepath.Join(repoDir, "new.txt"))
+	require.Equal(t, ".gitignore", git(t, repoDir, "diff", "--cached", "--name-only"))
+	require.Equal(t, "main.txt", git(t, repoDir, "diff", "--name-only"))
+
+	require.False(t, GitExistsBranch(ctx, repoDir, "refs/heads/chore/migration"))
+	require.Equal(t, existing, git(t, repoDir, "rev-parse", "existing"))
+	require.False(t, GitExistsBranch(ctx, repoDir, SnapshotRef))
+}
@@ -7,17 +7,14 @@ import (
 
 const Separator = string(filepath.Separator)
 
-type byPathSeparators []string
+// lessPathSeparators sorts paths with fewer path separators first
+func lessPathSeparators(a, b string) bool {
+	la := strings.Count(a, Separator)
+	lb := strings.Count(b, Separator)
 
-func (a byPathSeparators) Len() int      { return len(a) }
-func (a byPathSeparators) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
-func (a byPathSeparators) Less(i, j int) bool {
-	li := strings.Count(a[i], Separator)
-	lj := strings.Count(a[j], Separator)
-
-	if li == lj {
-		return a[i] < a[j]
+	if la == lb {
+		return a < b
 	}
 
-	return li < lj
+	return la < lb
 }
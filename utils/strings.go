@@ -5,22 +5,165 @@ import (
 	"strings"
 )
 
-func NewReplacer(m map[string]string) *strings.Replacer {
-	replace := make([]string, 0, len(m)*2)
+// Replacer replaces module paths only at path segment boundaries.
+// A module path matches in case it is preceded by neither a module path character nor a '/',
+// except for the '//' of a url scheme, and followed by either a '/', an optional '.git' suffix or any character that cannot
+// be part of a module path, e.g. '@', quotes, whitespace or the end of the input.
+type Replacer struct {
+	m map[string]string
+	// keys grouped by their leading run of module path characters, which is the host
+	// without port, sorted by length (longest first)
+	byHost map[string][]string
+}
+
+// AmbiguousMatch is an occurrence of a mapped module path that was not replaced,
+// because it is only the prefix of a longer path segment, e.g. repo in repo-extra.
+type AmbiguousMatch struct {
+	Path  string // file path, empty in case the match was not found in a file
+	Line  int
+	Old   string // the mapped module path
+	Token string // the token that contains the mapped module path
+}
 
+func NewReplacer(m map[string]string) *Replacer {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
 	}
 
+	// we want to replace longer names before shorter names
 	sort.Sort(byLen(keys))
 
+	// the input is scanned with the same tokenization, e.g. git.company.com:7999/project
+	// belongs to the host git.company.com
+	byHost := make(map[string][]string, len(keys))
 	for _, key := range keys {
-		replace = append(replace, key, m[key])
+		host := key[:tokenHostEnd(key, 0)]
+		byHost[host] = append(byHost[host], key)
 	}
 
-	// we want to replace longer names before shorter names
-	return strings.NewReplacer(replace...)
+	return &Replacer{
+		m:      m,
+		byHost: byHost,
+	}
+}
+
+// Replace replaces all mapped module paths in s.
+func (r *Replacer) Replace(s string) string {
+	result, _ := r.ReplaceWithReport(s)
+	return result
+}
+
+// ReplaceWithReport replaces all mapped module paths in s and additionally returns
+// all occurrences that were not replaced because they did not end at a path segment boundary.
+func (r *Replacer) ReplaceWithReport(s string) (string, []AmbiguousMatch) {
+	var (
+		sb        strings.Builder
+		ambiguous []AmbiguousMatch
+		line      = 1
+		last      = 0
+	)
+
+	for i := 0; i < len(s); {
+		if s[i] == '\n' {
+			line++
+		}
+
+		if !isPathStart(s, i) || !isModulePathChar(s[i]) {
+			i++
+			continue
+		}
+
+		hostEnd := tokenHostEnd(s, i)
+
+		// occurrences of longer keys are only ambiguous in case no shorter key matches
+		var (
+			keys    = r.byHost[s[i:hostEnd]]
+			matched = false
+			pending []AmbiguousMatch
+		)
+		for _, key := range keys {
+			if !strings.HasPrefix(s[i:], key) {
+				continue
+			}
+
+			end := i + len(key)
+			if !isPathBoundary(s, end) {
+				pending = append(pending, AmbiguousMatch{
+					Line:  line,
+					Old:   key,
+					Token: s[i:tokenEnd(s, end)],
+				})
+				continue
+			}
+
+			if sb.Cap() == 0 {
+				sb.Grow(len(s) + 64)
+			}
+			sb.WriteString(s[last:i])
+			sb.WriteString(r.m[key])
+			last = end
+			i = end
+			matched = true
+			break
+		}
+
+		if !matched {
+			ambiguous = append(ambiguous, pending...)
+			i = hostEnd
+		}
+	}
+
+	if last == 0 {
+		return s, ambiguous
+	}
+	sb.WriteString(s[last:])
+	return sb.String(), ambiguous
+}
+
+func isModulePathChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~+", c) >= 0
+}
+
+// tokenHostEnd returns the end of the run of module path characters that starts at idx.
+func tokenHostEnd(s string, idx int) int {
+	for idx < len(s) && isModulePathChar(s[idx]) {
+		idx++
+	}
+	return idx
+}
+
+// isPathStart returns false in case idx is within a longer path,
+// e.g. mirror.example.com/git.company.com/project/repo, but not after the '//' of a url.
+func isPathStart(s string, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+	if s[idx-1] == '/' {
+		return idx >= 2 && s[idx-2] == '/'
+	}
+	return !isModulePathChar(s[idx-1])
+}
+
+func isPathBoundary(s string, idx int) bool {
+	if idx >= len(s) || !isModulePathChar(s[idx]) {
+		return true
+	}
+
+	// allow git clone urls
+	rest := strings.TrimPrefix(s[idx:], ".git")
+	return len(rest) < len(s[idx:]) && (len(rest) == 0 || !isModulePathChar(rest[0]))
+}
+
+func tokenEnd(s string, idx int) int {
+	for idx < len(s) && (isModulePathChar(s[idx]) || s[idx] == '/') {
+		idx++
+	}
+	return idx
 }
 
 type byLen []string
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplacerBoundaries(t *testing.T) {
+	r := NewReplacer(map[string]string{
+		"git.company.com/project/repo":     "github.com/company/repo",
+		"git.company.com/project/repo/sub": "github.com/company/sub",
+	})
+
+	table := []struct {
+		in   string
+		out  string
+		skip int
+	}{
+		{"git.company.com/project/repo", "github.com/company/repo", 0},
+		{`"git.company.com/project/repo/pkg"`, `"github.com/company/repo/pkg"`, 0},
+		{"git.company.com/project/repo@v1.0.0", "github.com/company/repo@v1.0.0", 0},
+		{"git.company.com/project/repo/sub/pkg", "github.com/company/sub/pkg", 0},
+		{"https://git.company.com/project/repo.git", "https://github.com/company/repo.git", 0},
+		{"git.company.com/project/repo-extra", "git.company.com/project/repo-extra", 1},
+		{"git.company.com/project/repository", "git.company.com/project/repository", 1},
+		{"sub.git.company.com/project/repo", "sub.git.company.com/project/repo", 0},
+		{"mirror.example.com/git.company.com/project/repo", "mirror.example.com/git.company.com/project/repo", 0},
+		{"../vendor/git.company.com/project/repo", "../vendor/git.company.com/project/repo", 0},
+		{"a git.company.com/project/repo\nb git.company.com/project/repo-x", "a github.com/company/repo\nb git.company.com/project/repo-x", 1},
+	}
+
+	for _, tc := range table {
+		out, skipped := r.ReplaceWithReport(tc.in)
+		require.Equal(t, tc.out, out, tc.in)
+		require.Len(t, skipped, tc.skip, tc.in)
+	}
+
+	_, skipped := r.ReplaceWithReport("x\ngit.company.com/project/repository")
+	require.Equal(t, 2, skipped[0].Line)
+	require.Equal(t, "git.company.com/project/repository", skipped[0].Token)
+}
+
+func TestReplacerPorts(t *testing.T) {
+	r := NewReplacer(map[string]string{
+		"git.company.com:7999/project/repo": "github.com/company/repo",
+		"git.company.com/project/repo":      "github.com/company/module",
+	})
+
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"ssh://git@git.company.com:7999/project/repo.git", "ssh://git@github.com/company/repo.git"},
+		{"git.company.com:7999/project/repo/pkg", "github.com/company/repo/pkg"},
+		{"git.company.com/project/repo/pkg", "github.com/company/module/pkg"},
+		{"git.company.com:8080/project/repo", "git.company.com:8080/project/repo"},
+	}
+	for _, tc := range table {
+		out, skipped := r.ReplaceWithReport(tc.in)
+		require.Equal(t, tc.out, out, tc.in)
+		require.Empty(t, skipped, tc.in)
+	}
+}
+
+func TestReplacerOverlappingPrefixes(t *testing.T) {
+	r := NewReplacer(map[string]string{
+		"git.company.com/project":          "github.com/project",
+		"git.company.com/project/repo":     "github.com/company/repo",
+		"git.company.com/project/repo/sub": "github.com/company/sub",
+	})
+
+	table := []struct {
+		in   string
+		out  string
+		skip []string
+	}{
+		{"git.company.com/project/repo/sub/pkg", "github.com/company/sub/pkg", nil},
+		{"git.company.com/project/repo/pkg", "github.com/company/repo/pkg", nil},
+		{"git.company.com/project/other", "github.com/project/other", nil},
+		// a shorter key wins, so the longer keys are not ambiguous
+		{"git.company.com/project/repo/subtle", "github.com/company/repo/subtle", nil},
+		{"git.company.com/project/repository", "github.com/project/repository", nil},
+		// no key matches
+		{"git.company.com/projects/repo", "git.company.com/projects/repo", []string{"git.company.com/project"}},
+	}
+	for _, tc := range table {
+		out, skipped := r.ReplaceWithReport(tc.in)
+		require.Equal(t, tc.out, out, tc.in)
+
+		old := make([]string, 0, len(skipped))
+		for _, a := range skipped {
+			old = append(old, a.Old)
+		}
+		require.ElementsMatch(t, tc.skip, old, tc.in)
+	}
+}
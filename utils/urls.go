@@ -7,13 +7,49 @@ import (
 	giturls "github.com/whilp/git-urls"
 )
 
-func ToModuleUrl(gitUrl string) (string, error) {
+// ToHost returns the lower case host name of a git url without port.
+func ToHost(gitUrl string) (string, error) {
 	u, err := giturls.Parse(gitUrl)
 	if err != nil {
 		return "", fmt.Errorf("invalid git url: %s: %w", gitUrl, err)
 	}
-	u.Scheme = ""
-	u.User = nil
+	return strings.ToLower(u.Hostname()), nil
+}
+
+// ToRepoPath returns the slash separated host/project/repo path of a git url
+// without scheme, user, port and .git suffix, e.g. for the directory of a clone.
+func ToRepoPath(gitUrl string) (string, error) {
+	u, err := giturls.Parse(gitUrl)
+	if err != nil {
+		return "", fmt.Errorf("invalid git url: %s: %w", gitUrl, err)
+	}
+
+	p := strings.Trim(strings.TrimSuffix(u.Path, ".git"), "/")
+	if u.Hostname() == "" {
+		return p, nil
+	}
+	return u.Hostname() + "/" + p, nil
+}
 
-	return strings.TrimSuffix(strings.TrimLeft(u.String(), "/"), ".git"), nil
+// RepoIdentity returns the canonical identity host/owner/repo of a git url, so that all url forms of the same
+// repository are equal: scheme, user, port and the .git suffix are removed and the identity is lower case,
+// because git hosting services ignore the case. The scm/ path prefix is only removed from http urls of the
+// form scm/project/repo, which is the http clone url of Bitbucket Server.
+func RepoIdentity(gitUrl string) (string, error) {
+	u, err := giturls.Parse(gitUrl)
+	if err != nil {
+		return "", fmt.Errorf("invalid git url: %s: %w", gitUrl, err)
+	}
+
+	p, err := ToRepoPath(gitUrl)
+	if err != nil {
+		return "", err
+	}
+
+	host, path, found := strings.Cut(p, "/")
+	isHttp := u.Scheme == "http" || u.Scheme == "https"
+	if found && isHttp && strings.HasPrefix(path, "scm/") && strings.Count(path, "/") == 2 {
+		p = host + "/" + strings.TrimPrefix(path, "scm/")
+	}
+	return strings.ToLower(p), nil
 }
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToRepoPath(t *testing.T) {
+	for url, expected := range map[string]string{
+		"ssh://git@git.company.com:7999/project/repo.git": "git.company.com/project/repo",
+		"git@github.com:company/repo.git":                 "github.com/company/repo",
+		"https://github.com/company/repo":                 "github.com/company/repo",
+		"/tmp/remote/repo.git":                            "tmp/remote/repo",
+	} {
+		p, err := ToRepoPath(url)
+		require.NoError(t, err)
+		require.Equal(t, expected, p, url)
+	}
+}
+
+func TestRepoIdentity(t *testing.T) {
+	for _, url := range []string{
+		"ssh://git@git.company.com:7999/project/repo.git",
+		"https://git.company.com/scm/project/repo.git",
+		"https://user@Git.Company.com/scm/Project/Repo",
+		"git@git.company.com:project/repo.git",
+	} {
+		id, err := RepoIdentity(url)
+		require.NoError(t, err)
+		require.Equal(t, "git.company.com/project/repo", id, url)
+	}
+
+	// scm is an ordinary owner or group name of ssh urls and of other hosts
+	for url, expected := range map[string]string{
+		"ssh://git@git.company.com:7999/scm/repo.git": "git.company.com/scm/repo",
+		"git@github.com:scm/repo.git":                 "github.com/scm/repo",
+		"https://github.com/scm/repo":                 "github.com/scm/repo",
+		"https://gitlab.com/scm/group/sub/repo.git":   "gitlab.com/scm/group/sub/repo",
+	} {
+		id, err := RepoIdentity(url)
+		require.NoError(t, err)
+		require.Equal(t, expected, id, url)
+	}
+}
+
+func TestModulePathRules(t *testing.T) {
+	rules, err := ParseModulePathRules([]string{
+		"git.company.com=strip-port+strip-prefix:scm+lowercase",
+		"gitlab.company.com=subgroups",
+		"*=git-suffix:keep",
+	})
+	require.NoError(t, err)
+
+	for url, expected := range map[string]string{
+		"ssh://git@git.company.com:7999/Project/Repo.git":   "git.company.com/project/repo",
+		"https://user@git.company.com/scm/project/repo.git": "git.company.com/project/repo",
+		"git@gitlab.company.com:group/sub/repo":             "gitlab.company.com/group/sub/repo.git",
+		"git@gitlab.company.com:group/repo.git":             "gitlab.company.com/group/repo",
+		"git@github.com:company/repo.git":                   "github.com/company/repo.git",
+	} {
+		p, err := rules.ToModuleUrl(url)
+		require.NoError(t, err)
+		require.Equal(t, expected, p, url)
+	}
+
+	_, err = ParseModulePathRules([]string{"git.company.com=strip-suffix"})
+	require.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// worktreeName is the directory of the migration worktree inside of the git directory,
+// where it is neither found by FindRepoDirs nor visible in the working tree of the repository.
+const worktreeName = "module-migration/worktree"
+
+// GitWorktreeDir returns the directory of the migration worktree of the repository.
+// The directory does not necessarily exist.
+func GitWorktreeDir(ctx context.Context, repoDir string) (string, error) {
+	lines, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", fmt.Errorf("failed to get git directory of %s: %w", repoDir, err)
+	}
+	lines = removeEmptyLines(lines)
+	if len(lines) != 1 {
+		return "", fmt.Errorf("expected only one line when getting the git directory: %s", strings.Join(lines, "\n"))
+	}
+	return filepath.Join(lines[0], filepath.FromSlash(worktreeName)), nil
+}
+
+// GitWorktreeAdd creates the worktree directory with the branch that is created or reset to the start point.
+// A previous worktree in the same directory is removed. An existing branch is only reset in case it has no commits
+// that are reachable from none of the protected refs or in case force is set.
+func GitWorktreeAdd(ctx context.Context, repoDir, worktreeDir, branch, startPoint string, force bool, protected ...string) error {
+	create := "-b"
+	if GitExistsBranch(ctx, repoDir, "refs/heads/"+branch) {
+		if !force {
+			err := GitCheckBranchPushed(ctx, repoDir, branch, protected...)
+			if err != nil {
+				return err
+			}
+		}
+		create = "-B"
+	}
+
+	_, found, err := Exists(worktreeDir)
+	if err != nil {
+		return err
+	}
+	if found && GitWorktreeRemove(ctx, repoDir, worktreeDir) != nil {
+		// left over directory that is no registered worktree anymore
+		err = os.RemoveAll(worktreeDir)
+		if err != nil {
+			return err
+		}
+		_, _ = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "worktree", "prune")
+	}
+
+	_, err = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "worktree", "add", create, branch, worktreeDir, startPoint)
+	if err != nil {
+		return fmt.Errorf("failed to add worktree %s of branch %s in %s: %w", worktreeDir, branch, repoDir, err)
+	}
+	return nil
+}
+
+// GitWorktreeRemove removes the worktree directory including its local changes.
+func GitWorktreeRemove(ctx context.Context, repoDir, worktreeDir string) error {
+	_, err := ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "worktree", "remove", "--force", worktreeDir)
+	if err != nil {
+		return fmt.Errorf("failed to remove worktree %s of %s: %w", worktreeDir, repoDir, err)
+	}
+
+	// forget worktrees whose directories were deleted manually
+	_, _ = ExecuteQuietPathApplicationWithOutput(ctx, repoDir, "git", "worktree", "prune")
+	return nil
+}